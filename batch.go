@@ -0,0 +1,246 @@
+package rochefort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when using BatchAppender or Producer after Close
+var ErrClosed = errors.New("closed")
+
+// BatchConfig controls when BatchAppender flushes, the batch is sent when any of the limits is reached
+type BatchConfig struct {
+	// maximum number of appends in one request, default 100
+	MaxCount int
+	// maximum sum of the data sizes in one request, default 1MB, a single append bigger than that is sent alone
+	MaxBytes int
+	// how long the first append in a batch waits for more appends, default 5ms
+	Linger time.Duration
+	// maximum number of batches sent at the same time, default 4, when reached the appends block until a batch is stored (used only by BatchAppender)
+	MaxInFlight int
+}
+
+func (this BatchConfig) withDefaults() BatchConfig {
+	if this.MaxCount <= 0 {
+		this.MaxCount = 100
+	}
+	if this.MaxBytes <= 0 {
+		this.MaxBytes = 1024 * 1024
+	}
+	if this.Linger <= 0 {
+		this.Linger = 5 * time.Millisecond
+	}
+	if this.MaxInFlight <= 0 {
+		this.MaxInFlight = 4
+	}
+	return this
+}
+
+// AppendFuture is the result of BatchAppender.Append, it is resolved when the batch containing the append is stored
+type AppendFuture struct {
+	done   chan struct{}
+	offset uint64
+	err    error
+}
+
+// Done is closed when the result is available
+func (this *AppendFuture) Done() <-chan struct{} {
+	return this.done
+}
+
+// Wait blocks until the append is stored, returns the stored offset and error, like Append in case of error the offset is 0
+func (this *AppendFuture) Wait() (uint64, error) {
+	<-this.done
+	return this.offset, this.err
+}
+
+type pendingAppend struct {
+	append   *Append
+	callback func(offset uint64, err error)
+}
+
+// BatchAppender coalesces appends from many goroutines into one AppendInput, each caller gets its own offset
+// example:
+//
+//	b := NewBatchAppender(r, BatchConfig{Linger: 10 * time.Millisecond})
+//	defer b.Close()
+//	offset, err := b.Append(&Append{Namespace: ns, Data: data}).Wait()
+type BatchAppender struct {
+	client Interface
+	config BatchConfig
+
+	lock       sync.Mutex
+	sent       *sync.Cond
+	pending    []*pendingAppend
+	size       int
+	generation uint64
+	linger     *time.Timer
+	// the flushed batches that are not stored yet, and how many of them are being sent
+	inflight map[uint64]bool
+	sending  int
+	closed   bool
+}
+
+// NewBatchAppender creates batching appender on top of the client, it must be closed to flush the remaining appends
+func NewBatchAppender(client Interface, config BatchConfig) *BatchAppender {
+	this := &BatchAppender{
+		client:   client,
+		config:   config.withDefaults(),
+		inflight: map[uint64]bool{},
+	}
+	this.sent = sync.NewCond(&this.lock)
+	return this
+}
+
+// Append adds the append to the current batch, the returned future is resolved when the batch is stored
+func (this *BatchAppender) Append(a *Append) *AppendFuture {
+	future := &AppendFuture{done: make(chan struct{})}
+	this.AppendFunc(a, func(offset uint64, err error) {
+		future.offset = offset
+		future.err = err
+		close(future.done)
+	})
+	return future
+}
+
+// AppendFunc is like Append, but the result is delivered to the callback, which is called from the goroutine sending the batch
+// when the append completes a batch while MaxInFlight batches are being sent, it blocks until one of them is stored, so the callback must not append to the same BatchAppender
+func (this *BatchAppender) AppendFunc(a *Append, callback func(offset uint64, err error)) {
+	this.lock.Lock()
+	if this.closed {
+		this.lock.Unlock()
+		callback(0, ErrClosed)
+		return
+	}
+
+	if this.fullLocked(len(a.Data)) {
+		this.flushLocked()
+		// flushLocked can wait for a batch to be stored, and Close can run meanwhile
+		if this.closed {
+			this.lock.Unlock()
+			callback(0, ErrClosed)
+			return
+		}
+	}
+	this.pending = append(this.pending, &pendingAppend{append: a, callback: callback})
+	this.size += len(a.Data)
+
+	if len(this.pending) == 1 {
+		generation := this.generation
+		this.linger = time.AfterFunc(this.config.Linger, func() {
+			this.lock.Lock()
+			if this.generation == generation {
+				this.flushLocked()
+			}
+			this.lock.Unlock()
+		})
+	}
+
+	if len(this.pending) >= this.config.MaxCount || this.size >= this.config.MaxBytes {
+		this.flushLocked()
+	}
+	this.lock.Unlock()
+}
+
+// fullLocked returns true if the append of the given size does not fit in the current batch, must be called with the lock held
+func (this *BatchAppender) fullLocked(size int) bool {
+	if len(this.pending) == 0 {
+		return false
+	}
+	return len(this.pending) >= this.config.MaxCount || this.size+size > this.config.MaxBytes
+}
+
+// flushLocked sends the current batch in the background, waiting while MaxInFlight batches are being sent, must be called with the lock held
+func (this *BatchAppender) flushLocked() {
+	if len(this.pending) == 0 {
+		return
+	}
+	if this.linger != nil {
+		this.linger.Stop()
+		this.linger = nil
+	}
+	batch := this.pending
+	id := this.generation
+	this.pending = nil
+	this.size = 0
+	this.generation++
+	this.inflight[id] = true
+
+	for this.sending >= this.config.MaxInFlight {
+		this.sent.Wait()
+	}
+	this.sending++
+
+	go func() {
+		this.send(batch)
+
+		this.lock.Lock()
+		this.sending--
+		delete(this.inflight, id)
+		this.sent.Broadcast()
+		this.lock.Unlock()
+	}()
+}
+
+// waitLocked waits until all the batches flushed so far are stored, must be called with the lock held
+func (this *BatchAppender) waitLocked() {
+	last := this.generation
+	for {
+		waiting := false
+		for id := range this.inflight {
+			if id < last {
+				waiting = true
+				break
+			}
+		}
+		if !waiting {
+			return
+		}
+		this.sent.Wait()
+	}
+}
+
+func (this *BatchAppender) send(batch []*pendingAppend) {
+	input := &AppendInput{
+		AppendPayload: make([]*Append, len(batch)),
+	}
+	for i, p := range batch {
+		input.AppendPayload[i] = p.append
+	}
+
+	out, err := this.client.SetContext(context.Background(), input)
+	if err == nil && len(out.Offset) != len(batch) {
+		err = fmt.Errorf("expected %d offsets, but got: %d", len(batch), len(out.Offset))
+	}
+
+	for i, p := range batch {
+		if err != nil {
+			p.callback(0, err)
+		} else {
+			p.callback(out.Offset[i], nil)
+		}
+	}
+}
+
+// Flush sends the current batch and waits until all the batches sent so far are stored
+func (this *BatchAppender) Flush() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.flushLocked()
+	this.waitLocked()
+}
+
+// Close flushes the remaining appends and waits for them, appends after Close (or still waiting for a batch to be stored when Close is called) fail with ErrClosed
+func (this *BatchAppender) Close() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.closed = true
+	this.flushLocked()
+	this.waitLocked()
+	return nil
+}
@@ -0,0 +1,216 @@
+package rochefort_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestBatchAppender(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	b := rochefort.NewBatchAppender(r, rochefort.BatchConfig{MaxCount: 10, Linger: time.Hour})
+
+	var wg sync.WaitGroup
+	offsets := make([]uint64, 100)
+	errs := make([]error, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			offsets[i], errs[i] = b.Append(&rochefort.Append{
+				Namespace: "batch",
+				Data:      []byte(fmt.Sprintf("value-%d", i)),
+			}).Wait()
+		}(i)
+	}
+	wg.Wait()
+
+	if server.Requests("set") != 10 {
+		t.Logf("expected 10 batches, got: %d", server.Requests("set"))
+		t.FailNow()
+	}
+
+	for i := range offsets {
+		if errs[i] != nil {
+			t.Log(errs[i])
+			t.FailNow()
+		}
+		data, err := r.GetOne("batch", offsets[i])
+		if err != nil || string(data) != fmt.Sprintf("value-%d", i) {
+			t.Logf("unexpected read at %d: %s, %v", offsets[i], string(data), err)
+			t.FailNow()
+		}
+	}
+
+	b.Close()
+	_, err := b.Append(&rochefort.Append{Namespace: "batch"}).Wait()
+	if !errors.Is(err, rochefort.ErrClosed) {
+		t.Logf("expected ErrClosed, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestBatchAppenderLingerAndFlush(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	b := rochefort.NewBatchAppender(r, rochefort.BatchConfig{Linger: 10 * time.Millisecond})
+	defer b.Close()
+
+	_, err := b.Append(&rochefort.Append{Namespace: "batch", Data: []byte("a")}).Wait()
+	if err != nil || server.Requests("set") != 1 {
+		t.Logf("expected linger flush, got: %d, %v", server.Requests("set"), err)
+		t.FailNow()
+	}
+
+	b2 := rochefort.NewBatchAppender(r, rochefort.BatchConfig{MaxBytes: 4, Linger: time.Hour})
+	defer b2.Close()
+	f1 := b2.Append(&rochefort.Append{Namespace: "batch", Data: []byte("ab")})
+	f2 := b2.Append(&rochefort.Append{Namespace: "batch", Data: []byte("cd")})
+	f3 := b2.Append(&rochefort.Append{Namespace: "batch", Data: []byte("e")})
+	<-f1.Done()
+	<-f2.Done()
+	b2.Flush()
+	select {
+	case <-f3.Done():
+	default:
+		t.Log("expected Flush to store the pending append")
+		t.FailNow()
+	}
+	if server.Requests("set") != 3 {
+		t.Logf("expected 3 batches, got: %d", server.Requests("set"))
+		t.FailNow()
+	}
+}
+
+// slowTransport delays every request and remembers the highest number of concurrent requests
+type slowTransport struct {
+	lock    sync.Mutex
+	current int
+	max     int
+}
+
+func (this *slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	this.lock.Lock()
+	this.current++
+	if this.current > this.max {
+		this.max = this.current
+	}
+	this.lock.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+	resp, err := http.DefaultTransport.RoundTrip(req)
+
+	this.lock.Lock()
+	this.current--
+	this.lock.Unlock()
+	return resp, err
+}
+
+func TestBatchAppenderLimits(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	transport := &slowTransport{}
+	r := rochefort.New(server.URL, rochefort.WithHTTPClient(&http.Client{Transport: transport}))
+
+	b := rochefort.NewBatchAppender(r, rochefort.BatchConfig{MaxCount: 1, MaxInFlight: 2, Linger: time.Hour})
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.Append(&rochefort.Append{Namespace: "limits", Data: []byte("a")})
+		}()
+	}
+	wg.Wait()
+	b.Close()
+	if transport.max > 2 || server.Requests("set") != 20 {
+		t.Logf("expected at most 2 batches in flight, got: %d, requests: %d", transport.max, server.Requests("set"))
+		t.FailNow()
+	}
+
+	// the append that does not fit is sent in the next batch
+	b = rochefort.NewBatchAppender(r, rochefort.BatchConfig{MaxBytes: 10, Linger: time.Hour})
+	futures := []*rochefort.AppendFuture{}
+	for _, data := range []string{"abcdef", "ghijkl", "mnopqrstuvwxyz"} {
+		futures = append(futures, b.Append(&rochefort.Append{Namespace: "limits", Data: []byte(data)}))
+	}
+	b.Close()
+	for _, f := range futures {
+		if _, err := f.Wait(); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+	if server.Requests("set") != 23 {
+		t.Logf("expected 3 batches, got: %d", server.Requests("set")-20)
+		t.FailNow()
+	}
+}
+
+// gateTransport blocks every request until the gate is closed
+type gateTransport struct {
+	gate chan struct{}
+}
+
+func (this *gateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-this.gate
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestBatchAppenderCloseWhileWaiting(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	transport := &gateTransport{gate: make(chan struct{})}
+	r := rochefort.New(server.URL, rochefort.WithHTTPClient(&http.Client{Transport: transport}))
+
+	b := rochefort.NewBatchAppender(r, rochefort.BatchConfig{MaxBytes: 4, MaxInFlight: 1, Linger: time.Hour})
+	first := b.Append(&rochefort.Append{Namespace: "close", Data: []byte("abcd")})
+	second := b.Append(&rochefort.Append{Namespace: "close", Data: []byte("ef")})
+
+	// the third append does not fit, so it flushes the second one and waits for the first batch to be stored
+	third := make(chan *rochefort.AppendFuture)
+	go func() {
+		third <- b.Append(&rochefort.Append{Namespace: "close", Data: []byte("ghi")})
+	}()
+	// the sleeps only order the goroutines, if Close wins the race the append fails with ErrClosed right away and the test still passes
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		b.Close()
+		close(closed)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(transport.gate)
+	<-closed
+
+	for _, f := range []*rochefort.AppendFuture{first, second} {
+		if _, err := f.Wait(); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+	select {
+	case f := <-third:
+		select {
+		case <-f.Done():
+		case <-time.After(time.Second):
+			t.Log("expected the append accepted before Close to be resolved")
+			t.FailNow()
+		}
+	case <-time.After(time.Second):
+		t.Log("expected the append to return")
+		t.FailNow()
+	}
+}
@@ -1,21 +1,27 @@
 /*
 this package provides a client for https://github.com/jackdoe/rochefort disk speed append + offset service (poor man's kafka)
+
+requires go 1.19 or newer
 */
 package rochefort
 
 import (
 	"bytes"
-	"encoding/binary"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	neturl "net/url"
+	"path"
 	"strings"
 	"time"
 )
 
+// ErrStop can be returned from ScanFunc and SearchFunc callbacks to stop reading early
+var ErrStop = errors.New("stop")
+
 type Client struct {
 	url        string
 	getUrl     string
@@ -24,10 +30,22 @@ type Client struct {
 	scanUrl    string
 	deleteUrl  string
 	compactUrl string
+	statsUrl   string
 	http       *http.Client
+	retry      *RetryPolicy
+
+	timeouts          map[Operation]time.Duration
+	streamIdleTimeout time.Duration
+	userAgent         string
+	headers           http.Header
+	codecs            []PayloadCodec
+	observers         []Observer
+	tracer            Tracer
+	interceptors      []Interceptor
 }
 
 // Creates new client, takes rochefort url and http client (or nil, at which case it uses a client with 1 second timeout)
+// use New to configure per operation timeouts and the rest of the options
 func NewClient(url string, httpClient *http.Client) *Client {
 	if httpClient == nil {
 		httpClient = &http.Client{
@@ -37,19 +55,33 @@ func NewClient(url string, httpClient *http.Client) *Client {
 			Timeout: time.Duration(1) * time.Second,
 		}
 	}
+	return newClient(url, &options{httpClient: httpClient})
+}
+
+func newClient(url string, o *options) *Client {
 	if !strings.HasSuffix(url, "/") {
 		url = fmt.Sprintf("%s/", url)
 	}
 
 	return &Client{
-		url:        url,
-		getUrl:     fmt.Sprintf("%sget", url),
-		setUrl:     fmt.Sprintf("%sset", url),
-		queryUrl:   fmt.Sprintf("%squery", url),
-		scanUrl:    fmt.Sprintf("%sscan", url),
-		compactUrl: fmt.Sprintf("%scompact", url),
-		deleteUrl:  fmt.Sprintf("%sdelete", url),
-		http:       httpClient,
+		url:               url,
+		getUrl:            fmt.Sprintf("%sget", url),
+		setUrl:            fmt.Sprintf("%sset", url),
+		queryUrl:          fmt.Sprintf("%squery", url),
+		scanUrl:           fmt.Sprintf("%sscan", url),
+		compactUrl:        fmt.Sprintf("%scompact", url),
+		deleteUrl:         fmt.Sprintf("%sdelete", url),
+		statsUrl:          fmt.Sprintf("%sstat", url),
+		http:              o.httpClient,
+		retry:             o.retry,
+		timeouts:          o.timeouts,
+		streamIdleTimeout: o.streamIdleTimeout,
+		userAgent:         o.userAgent,
+		headers:           o.headers,
+		codecs:            o.codecs,
+		observers:         o.observers,
+		tracer:            o.tracer,
+		interceptors:      o.interceptors,
 	}
 }
 
@@ -57,26 +89,36 @@ func NewClient(url string, httpClient *http.Client) *Client {
 // allocSize parameter is used if you want to allocate more space than your data, so you can inplace modify it; can be 0
 // the tags parameter is used to build online inverted index that can be used from Scan()
 func (this *Client) Set(input *AppendInput) (*AppendOutput, error) {
-	data, err := input.Marshal()
-	if err != nil {
+	return this.SetContext(context.Background(), input)
+}
+
+// SetContext is like Set, but the request is bound to ctx
+// it is retried only if it has no appends or ctx is marked with Idempotent
+func (this *Client) SetContext(ctx context.Context, input *AppendInput) (*AppendOutput, error) {
+	call := &SetCall{Input: input}
+	if err := this.intercept(ctx, call); err != nil {
 		return nil, err
 	}
-	resp, err := this.http.Post(this.setUrl, "application/octet-stream", bytes.NewReader(data))
-	if err != nil {
-		return nil, err
+	if call.Output == nil {
+		return nil, ErrNoOutput
 	}
-	defer resp.Body.Close()
+	return call.Output, nil
+}
 
-	if resp.StatusCode != 200 {
-		return nil, nonOkError(resp.StatusCode, resp.Body)
+func (this *Client) set(ctx context.Context, input *AppendInput) (*AppendOutput, error) {
+	if len(input.AppendPayload) == 0 {
+		ctx = Idempotent(ctx)
 	}
-
-	body, err := ioutil.ReadAll(resp.Body)
+	input, err := this.encodeInput(input)
+	if err != nil {
+		return nil, err
+	}
+	data, err := input.Marshal()
 	if err != nil {
 		return nil, err
 	}
 	out := &AppendOutput{}
-	err = out.Unmarshal(body)
+	err = this.call(ctx, OpSet, "POST", this.setUrl, data, out)
 	if err != nil {
 		return nil, err
 	}
@@ -84,138 +126,250 @@ func (this *Client) Set(input *AppendInput) (*AppendOutput, error) {
 }
 
 func (this *Client) Compact(input *NamespaceInput) (*SuccessOutput, error) {
-	data, err := input.Marshal()
-	if err != nil {
+	return this.CompactContext(context.Background(), input)
+}
+
+// CompactContext is like Compact, but the request is bound to ctx
+func (this *Client) CompactContext(ctx context.Context, input *NamespaceInput) (*SuccessOutput, error) {
+	call := &CompactCall{Input: input}
+	if err := this.intercept(ctx, call); err != nil {
 		return nil, err
 	}
-	resp, err := this.http.Post(this.compactUrl, "application/octet-stream", bytes.NewReader(data))
-	if err != nil {
-		return nil, err
+	if call.Output == nil {
+		return nil, ErrNoOutput
 	}
-	defer resp.Body.Close()
+	return call.Output, nil
+}
 
-	if resp.StatusCode != 200 {
-		return nil, nonOkError(resp.StatusCode, resp.Body)
-	}
+func (this *Client) Delete(input *NamespaceInput) (*SuccessOutput, error) {
+	return this.DeleteContext(context.Background(), input)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+// DeleteContext is like Delete, but the request is bound to ctx
+func (this *Client) DeleteContext(ctx context.Context, input *NamespaceInput) (*SuccessOutput, error) {
+	call := &DeleteCall{Input: input}
+	if err := this.intercept(ctx, call); err != nil {
 		return nil, err
 	}
-	out := &SuccessOutput{}
-	err = out.Unmarshal(body)
-	if err != nil {
-		return nil, err
+	if call.Output == nil {
+		return nil, ErrNoOutput
 	}
-	return out, nil
+	return call.Output, nil
 }
 
-func (this *Client) Delete(input *NamespaceInput) (*SuccessOutput, error) {
+// namespaceCall sends Compact or Delete request
+func (this *Client) namespaceCall(ctx context.Context, op Operation, url string, input *NamespaceInput) (*SuccessOutput, error) {
 	data, err := input.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	resp, err := this.http.Post(this.deleteUrl, "application/octet-stream", bytes.NewReader(data))
+	out := &SuccessOutput{}
+	err = this.call(ctx, op, "POST", url, data, out)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return out, nil
+}
 
-	if resp.StatusCode != 200 {
-		return nil, nonOkError(resp.StatusCode, resp.Body)
-	}
+// Stats returns the per tag counts, the current offset and the backing file of the namespace
+func (this *Client) Stats(namespace string) (*StatsOutput, error) {
+	return this.StatsContext(context.Background(), namespace)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+// StatsContext is like Stats, but the request is bound to ctx
+func (this *Client) StatsContext(ctx context.Context, namespace string) (*StatsOutput, error) {
+	call := &StatsCall{Namespace: namespace}
+	if err := this.intercept(ctx, call); err != nil {
 		return nil, err
 	}
-	out := &SuccessOutput{}
-	err = out.Unmarshal(body)
+	if call.Output == nil {
+		return nil, ErrNoOutput
+	}
+	return call.Output, nil
+}
+
+// namespaceQuery returns the escaped query string of the namespace, so names with &, #, + or spaces reach the server unchanged
+func namespaceQuery(namespace string) string {
+	return neturl.Values{"namespace": {namespace}}.Encode()
+}
+
+func (this *Client) stats(ctx context.Context, namespace string) (*StatsOutput, error) {
+	url := fmt.Sprintf("%s?%s", this.statsUrl, namespaceQuery(namespace))
+
+	out := &StatsOutput{}
+	err := this.call(ctx, OpStats, "GET", url, nil, out)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func nonOkError(code int, body io.Reader) error {
-	b, err := ioutil.ReadAll(body)
-	if err != nil {
-		return errors.New(fmt.Sprintf("expected status code 200, but got: %d, couldnt read the body got: %s", code, err.Error()))
-	} else {
-		return errors.New(fmt.Sprintf("expected status code 200, but got: %d, body: %s", code, string(b)))
+func nonOkError(code int, endpoint string, body io.Reader) error {
+	b, _ := ioutil.ReadAll(body)
+	return &StatusError{
+		Code:     code,
+		Endpoint: endpoint,
+		Body:     b,
 	}
 }
 
-// Get fetches multiple records in one round trip
-func (this *Client) Get(input *GetInput) ([][]byte, error) {
-	b, err := input.Marshal()
-	if err != nil {
-		return nil, err
-	}
+// do sends the request and returns the response if the status code is 200, the caller must close the body
+// failed requests are retried according to the retry policy, but once the response is returned reading it is not retried
+// the operation timeout and the stream idle timeout apply until the body is closed
+func (this *Client) do(ctx context.Context, op Operation, method string, url string, contentType string, data []byte) (*http.Response, error) {
+	ctx, cancel := this.withTimeout(ctx, op)
 
-	resp, err := this.http.Post(this.getUrl, "application/octet-stream", bytes.NewReader(b))
+	var resp *http.Response
+	err := this.withRetry(ctx, op, func() error {
+		var err error
+		resp, err = this.doOnce(ctx, method, url, contentType, data)
+		return err
+	})
 	if err != nil {
+		cancel()
 		return nil, err
 	}
-	defer resp.Body.Close()
+	resp.Body = newStreamBody(resp.Body, cancel, this.streamIdleTimeout)
+	return resp, nil
+}
 
-	if resp.StatusCode != 200 {
-		return nil, nonOkError(resp.StatusCode, resp.Body)
+func (this *Client) doOnce(ctx context.Context, method string, url string, contentType string, data []byte) (*http.Response, error) {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
 	}
-	// XXX: read stream
-	body, err := ioutil.ReadAll(resp.Body)
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
-	out := &GetOutput{}
-	err = out.Unmarshal(body)
-	if err != nil {
-		return nil, err
+	req = req.WithContext(ctx)
+	o := observationFrom(ctx)
+	o.sent(len(data))
+	for k, v := range this.headers {
+		req.Header[k] = v
+	}
+	if this.userAgent != "" {
+		req.Header.Set("User-Agent", this.userAgent)
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if traceParent := o.traceParent(); traceParent != "" {
+		req.Header.Set("traceparent", traceParent)
 	}
-	return out.Data, nil
-}
-
-// Scan the whole namespace, callback called with rochefortOffset and the value at this offset
-func (this *Client) Scan(namespace string, callback func(rochefortOffset uint64, value []byte)) error {
-	url := fmt.Sprintf("%s?namespace=%s", this.scanUrl, namespace)
 
-	resp, err := this.http.Get(url)
+	resp, err := this.http.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	o.status(resp.StatusCode)
+	resp.Body = o.body(resp.Body)
 
 	if resp.StatusCode != 200 {
-		return nonOkError(resp.StatusCode, resp.Body)
+		defer resp.Body.Close()
+		return nil, nonOkError(resp.StatusCode, path.Base(req.URL.Path), resp.Body)
 	}
+	return resp, nil
+}
 
-	header := make([]byte, 12)
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
 
-	for {
-		_, err := io.ReadFull(resp.Body, header)
-		if err == io.EOF {
-			break
+// call sends protobuf encoded data and decodes the response into out, the whole exchange is retried according to the retry policy
+func (this *Client) call(ctx context.Context, op Operation, method string, url string, data []byte, out unmarshaler) error {
+	ctx, cancel := this.withTimeout(ctx, op)
+	defer cancel()
+
+	return this.withRetry(ctx, op, func() error {
+		resp, err := this.doOnce(ctx, method, url, "application/octet-stream", data)
+		if err != nil {
+			return err
 		}
+		defer resp.Body.Close()
 
+		// XXX: read stream
+		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
 			return err
 		}
+		return out.Unmarshal(body)
+	})
+}
+
+// Get fetches multiple records in one round trip
+func (this *Client) Get(input *GetInput) ([][]byte, error) {
+	return this.GetContext(context.Background(), input)
+}
 
-		len := binary.LittleEndian.Uint32(header)
-		offset := binary.LittleEndian.Uint64(header[4:])
+// GetContext is like Get, but the request is bound to ctx
+func (this *Client) GetContext(ctx context.Context, input *GetInput) ([][]byte, error) {
+	call := &GetCall{Input: input}
+	if err := this.intercept(ctx, call); err != nil {
+		return nil, err
+	}
+	if call.Output == nil && len(call.Input.GetPayload) > 0 {
+		return nil, ErrNoOutput
+	}
+	return call.Output, nil
+}
 
-		data := make([]byte, len)
-		_, err = io.ReadFull(resp.Body, data)
-		if err != nil {
-			return errors.New(fmt.Sprintf("expected at least %d bytes, but got EOF, error: %s", len, err.Error()))
+func (this *Client) get(ctx context.Context, input *GetInput) ([][]byte, error) {
+	b, err := input.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	out := &GetOutput{}
+	err = this.call(ctx, OpGet, "POST", this.getUrl, b, out)
+	if err != nil {
+		return nil, err
+	}
+	if len(this.codecs) > 0 {
+		if len(out.Data) != len(input.GetPayload) {
+			return nil, fmt.Errorf("expected %d records, but got: %d", len(input.GetPayload), len(out.Data))
 		}
+		for i, data := range out.Data {
+			out.Data[i], err = this.decode(input.GetPayload[i].Offset, data)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return out.Data, nil
+}
 
+// Scan the whole namespace, callback called with rochefortOffset and the value at this offset
+func (this *Client) Scan(namespace string, callback func(rochefortOffset uint64, value []byte)) error {
+	return this.ScanContext(context.Background(), namespace, callback)
+}
+
+// ScanContext is like Scan, but the request is bound to ctx, which is also checked between the records
+func (this *Client) ScanContext(ctx context.Context, namespace string, callback func(rochefortOffset uint64, value []byte)) error {
+	return this.ScanFuncContext(ctx, namespace, func(offset uint64, data []byte) error {
 		callback(offset, data)
-	}
-	return nil
+		return nil
+	})
+}
+
+// ScanFunc is like Scan, but the callback can stop the scan, if it returns ErrStop the scan stops and ScanFunc returns nil, any other error is returned as is
+func (this *Client) ScanFunc(namespace string, callback func(rochefortOffset uint64, value []byte) error) error {
+	return this.ScanFuncContext(context.Background(), namespace, callback)
+}
+
+// ScanFuncContext is like ScanFunc, but the request is bound to ctx, which is also checked between the records
+func (this *Client) ScanFuncContext(ctx context.Context, namespace string, callback func(rochefortOffset uint64, value []byte) error) error {
+	return this.intercept(ctx, &ScanCall{Namespace: namespace, Callback: callback})
+}
+
+func (this *Client) scan(ctx context.Context, namespace string) (*http.Response, error) {
+	url := fmt.Sprintf("%s?%s", this.scanUrl, namespaceQuery(namespace))
+
+	return this.do(ctx, OpScan, "GET", url, "", nil)
 }
 
 // Search the whole namespace based on the tagged (with Append tags) blobs, callback called with rochefortOffset and the value at this offset
+// the query is either a Query or the raw map[string]interface{} form
 // example:
 //
 // r.Search(ns, map[string]interface{}{
@@ -230,46 +384,81 @@ func (this *Client) Scan(namespace string, callback func(rochefortOffset uint64,
 // }, func(offset uint64, data []byte) {
 // 	scanned = append(scanned, string(data))
 // })
-func (this *Client) Search(namespace string, query map[string]interface{}, callback func(rochefortOffset uint64, value []byte)) error {
-	url := fmt.Sprintf("%s?namespace=%s", this.queryUrl, namespace)
+//
+// or with the typed Query, built with Tag, And, Or and Not, or parsed from the DSL with ParseQuery:
+//
+//	r.Search(ns, Or(Tag("a"), Tag("b")), callback)
+func (this *Client) Search(namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte)) error {
+	return this.SearchContext(context.Background(), namespace, query, callback)
+}
 
-	j, err := json.Marshal(query)
-	if err != nil {
-		return err
-	}
+// SearchContext is like Search, but the request is bound to ctx, which is also checked between the records
+func (this *Client) SearchContext(ctx context.Context, namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte)) error {
+	return this.SearchFuncContext(ctx, namespace, query, func(offset uint64, data []byte) error {
+		callback(offset, data)
+		return nil
+	})
+}
+
+// SearchFunc is like Search, but the callback can stop the search, if it returns ErrStop the search stops and SearchFunc returns nil, any other error is returned as is
+func (this *Client) SearchFunc(namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte) error) error {
+	return this.SearchFuncContext(context.Background(), namespace, query, callback)
+}
+
+// SearchFuncContext is like SearchFunc, but the request is bound to ctx, which is also checked between the records
+func (this *Client) SearchFuncContext(ctx context.Context, namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte) error) error {
+	return this.intercept(ctx, &SearchCall{Namespace: namespace, Query: query, Callback: callback})
+}
+
+func (this *Client) search(ctx context.Context, namespace string, query interface{}) (*http.Response, error) {
+	url := fmt.Sprintf("%s?%s", this.queryUrl, namespaceQuery(namespace))
 
-	resp, err := this.http.Post(url, "application/json", bytes.NewBuffer(j))
+	j, err := encodeQuery(query)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return nonOkError(resp.StatusCode, resp.Body)
-	}
+	return this.do(ctx, OpSearch, "POST", url, "application/json", j)
+}
 
-	header := make([]byte, 12)
+// stream opens the scan or search response and reads it into the callback, or returns Iterator over it if pull is set
+func (this *Client) stream(ctx context.Context, op Operation, namespace string, open func(ctx context.Context) (*http.Response, error), callback func(rochefortOffset uint64, value []byte) error, pull bool) (*Iterator, error) {
+	ctx, o := this.observe(ctx, op, namespace, 0)
+	resp, err := open(ctx)
+	if err == nil {
+		if pull {
+			return newIterator(ctx, resp.Body, this.frames(resp.Body), o), nil
+		}
+		err = readFrames(ctx, this.frames(resp.Body), o, callback)
+		resp.Body.Close()
+	}
+	o.finish(err)
+	return nil, err
+}
 
+// readFrames calls the callback for every record in the scan/query stream, ctx is checked between the records
+// the reading stops at the first error returned by the callback, ErrStop is not reported
+func readFrames(ctx context.Context, frames *frameReader, o *observation, callback func(rochefortOffset uint64, value []byte) error) error {
 	for {
-		_, err := io.ReadFull(resp.Body, header)
-		if err == io.EOF {
-			break
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
+		offset, data, err := frames.next()
+		if err == io.EOF {
+			return nil
+		}
 		if err != nil {
 			return err
 		}
 
-		len := binary.LittleEndian.Uint32(header)
-		offset := binary.LittleEndian.Uint64(header[4:])
-
-		data := make([]byte, len)
-		_, err = io.ReadFull(resp.Body, data)
+		o.record()
+		err = callback(offset, data)
+		if err == ErrStop {
+			return nil
+		}
 		if err != nil {
-			return errors.New(fmt.Sprintf("expected at least %d bytes, but got EOF, error: %s", len, err.Error()))
+			return err
 		}
-
-		callback(offset, data)
 	}
-	return nil
 }
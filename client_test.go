@@ -2,7 +2,12 @@ package rochefort
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 )
@@ -23,6 +28,75 @@ func generateTestCases(n int) [][]byte {
 	return out
 }
 
+// framesServer streams n records with value "abc" at offsets 0..n-1
+func framesServer(n int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := make([]byte, 12)
+		for i := 0; i < n; i++ {
+			binary.LittleEndian.PutUint32(header, 3)
+			binary.LittleEndian.PutUint64(header[4:], uint64(i))
+			w.Write(header)
+			w.Write([]byte("abc"))
+		}
+	}))
+}
+
+func TestScanContextCancel(t *testing.T) {
+	server := framesServer(10)
+	defer server.Close()
+
+	r := NewClient(server.URL, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	seen := 0
+	err := r.ScanContext(ctx, "ns", func(offset uint64, data []byte) {
+		seen++
+		if seen == 2 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Logf("expected context.Canceled, got: %v", err)
+		t.FailNow()
+	}
+	if seen != 2 {
+		t.Logf("expected to stop after 2 records, got: %d", seen)
+		t.FailNow()
+	}
+}
+
+func TestScanFuncStop(t *testing.T) {
+	server := framesServer(10)
+	defer server.Close()
+
+	r := NewClient(server.URL, nil)
+
+	seen := 0
+	err := r.ScanFunc("ns", func(offset uint64, data []byte) error {
+		seen++
+		if seen == 3 {
+			return ErrStop
+		}
+		return nil
+	})
+	if err != nil || seen != 3 {
+		t.Logf("expected to stop after 3 records without error, got: %d, %v", seen, err)
+		t.FailNow()
+	}
+
+	bad := errors.New("bad record")
+	seen = 0
+	err = r.SearchFunc("ns", Tag("a"), func(offset uint64, data []byte) error {
+		seen++
+		return bad
+	})
+	if err != bad || seen != 1 {
+		t.Logf("expected the callback error after 1 record, got: %d, %v", seen, err)
+		t.FailNow()
+	}
+}
+
 func TestModify(t *testing.T) {
 	host := os.Getenv("ROCHEFORT_TEST")
 	if host == "" {
@@ -77,6 +151,23 @@ func TestModify(t *testing.T) {
 		t.Logf("unexpected read: %s", string(data[0]))
 		t.FailNow()
 	}
+
+	// past the allocated size the record must stay untouched
+	_, err = r.Modify(ns, off, 3, []byte("xyz"))
+	if !errors.Is(err, ErrOutOfBounds) {
+		t.Logf("expected ErrOutOfBounds, got: %v", err)
+		t.FailNow()
+	}
+	data, err = r.Get(&GetInput{
+		GetPayload: []*Get{{
+			Namespace: ns,
+			Offset:    off,
+		}},
+	})
+	if err != nil || string(data[0]) != "azxcv" {
+		t.Logf("unexpected read: %q, %v", data, err)
+		t.FailNow()
+	}
 }
 
 func TestSearch(t *testing.T) {
@@ -137,6 +228,49 @@ func TestSearch(t *testing.T) {
 
 }
 
+func TestStats(t *testing.T) {
+	host := os.Getenv("ROCHEFORT_TEST")
+	if host == "" {
+		t.Skip("skipping test because of no ROCHEFORT_TEST env")
+	}
+	r := NewClient(host, nil)
+
+	ns := "stats"
+	before, err := r.Stats(ns)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	_, err = r.Set(&AppendInput{
+		AppendPayload: []*Append{{
+			Namespace: ns,
+			Tags:      []string{"x"},
+			Data:      []byte("abc"),
+		}},
+	})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	after, err := r.Stats(ns)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if after.Offset <= before.Offset {
+		t.Logf("offset did not grow: %d -> %d", before.Offset, after.Offset)
+		t.FailNow()
+	}
+
+	if after.Tags["x"] != before.Tags["x"]+1 {
+		t.Logf("unexpected tag count: %d", after.Tags["x"])
+		t.FailNow()
+	}
+}
+
 func TestEverything(t *testing.T) {
 	host := os.Getenv("ROCHEFORT_TEST")
 	if host == "" {
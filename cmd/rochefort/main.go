@@ -0,0 +1,349 @@
+/*
+rochefort is command line client for https://github.com/jackdoe/rochefort
+
+usage:
+
+	rochefort [-url http://localhost:8000] <command> [flags] [args]
+
+commands:
+
+	set      append record, read from -data, -file or stdin, prints the offset
+	get      print the records at the offsets given as arguments
+	modify   overwrite record in place at -offset from -pos
+	scan     print all the records of the namespace
+	search   print the records matching -query
+	stats    print the namespace stats as json
+	compact  compact the namespace
+	delete   delete the namespace
+
+the url defaults to $ROCHEFORT_URL or http://localhost:8000, use "rochefort <command> -h" for the flags of the command
+*/
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rochefort: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage: rochefort [-url url] <set|get|modify|scan|search|stats|compact|delete> [flags] [args]")
+
+type command struct {
+	client    *rochefort.Client
+	namespace string
+	format    string
+	offsets   bool
+	flags     *flag.FlagSet
+	stdin     io.Reader
+	stdout    io.Writer
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	global := flag.NewFlagSet("rochefort", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	defaultUrl := os.Getenv("ROCHEFORT_URL")
+	if defaultUrl == "" {
+		defaultUrl = "http://localhost:8000"
+	}
+	url := global.String("url", defaultUrl, "rochefort url")
+	timeout := global.Duration("timeout", 10*time.Second, "timeout of set, get, modify, stats, compact and delete")
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if global.NArg() == 0 {
+		return errUsage
+	}
+
+	name := global.Arg(0)
+	c := &command{
+		flags:  flag.NewFlagSet(name, flag.ContinueOnError),
+		stdin:  stdin,
+		stdout: stdout,
+	}
+	c.flags.SetOutput(stderr)
+	c.flags.StringVar(&c.namespace, "ns", "", "namespace")
+
+	var handler func() error
+	switch name {
+	case "set":
+		handler = c.set()
+	case "get":
+		handler = c.get()
+	case "modify":
+		handler = c.modify()
+	case "scan":
+		handler = c.scan()
+	case "search":
+		handler = c.search()
+	case "stats":
+		handler = c.stats()
+	case "compact":
+		handler = c.compact()
+	case "delete":
+		handler = c.delete()
+	default:
+		return fmt.Errorf("unknown command %q\n%s", name, errUsage.Error())
+	}
+
+	if err := c.flags.Parse(global.Args()[1:]); err != nil {
+		return err
+	}
+	if err := c.checkFormat(); err != nil {
+		return err
+	}
+
+	opts := []rochefort.Option{rochefort.WithUserAgent("rochefort-cli")}
+	for _, op := range []rochefort.Operation{rochefort.OpSet, rochefort.OpGet, rochefort.OpStats, rochefort.OpCompact, rochefort.OpDelete} {
+		opts = append(opts, rochefort.WithTimeout(op, *timeout))
+	}
+	c.client = rochefort.New(*url, opts...)
+	return handler()
+}
+
+// outputFlags adds the flags of the commands that print records
+func (this *command) outputFlags() {
+	this.flags.StringVar(&this.format, "format", "raw", "output format: raw (the value followed by newline), hex or json (json lines with offset and base64 data)")
+	this.flags.BoolVar(&this.offsets, "offsets", false, "prefix raw and hex output with the offset and a tab")
+}
+
+// inputFlags adds the flags of the commands that read a payload, and returns function reading it
+func (this *command) inputFlags() func() ([]byte, error) {
+	data := this.flags.String("data", "", "payload, if empty it is read from -file")
+	file := this.flags.String("file", "-", "file to read the payload from, - is stdin")
+	return func() ([]byte, error) {
+		if *data != "" {
+			return []byte(*data), nil
+		}
+		if *file == "-" {
+			return ioutil.ReadAll(this.stdin)
+		}
+		return ioutil.ReadFile(*file)
+	}
+}
+
+// checkFormat validates -format of the commands that print records, so a bad value fails before anything is sent to the server
+func (this *command) checkFormat() error {
+	switch this.format {
+	case "", "raw", "hex", "json":
+		return nil
+	}
+	return fmt.Errorf("%s: unknown format %q", this.flags.Name(), this.format)
+}
+
+func (this *command) print(offset uint64, data []byte) error {
+	var err error
+	switch this.format {
+	case "json":
+		err = json.NewEncoder(this.stdout).Encode(struct {
+			Offset uint64 `json:"offset"`
+			Data   []byte `json:"data"`
+		}{offset, data})
+	case "hex", "raw":
+		if this.offsets {
+			if _, err = fmt.Fprintf(this.stdout, "%d\t", offset); err != nil {
+				return err
+			}
+		}
+		if this.format == "hex" {
+			_, err = fmt.Fprintln(this.stdout, hex.EncodeToString(data))
+		} else {
+			_, err = this.stdout.Write(append(data, '\n'))
+		}
+	default:
+		err = fmt.Errorf("unknown format %q", this.format)
+	}
+	return err
+}
+
+func splitTags(tags string) []string {
+	out := []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func (this *command) set() func() error {
+	tags := this.flags.String("tags", "", "comma separated tags")
+	alloc := this.flags.Uint("alloc", 0, "allocate more space than the payload, so it can be modified in place")
+	lines := this.flags.Bool("lines", false, "append every line of the input as separate record, in one request")
+	read := this.inputFlags()
+
+	return func() error {
+		if *alloc > math.MaxUint32 {
+			return fmt.Errorf("set: -alloc %d is out of range, max %d", *alloc, uint32(math.MaxUint32))
+		}
+		data, err := read()
+		if err != nil {
+			return err
+		}
+
+		payloads := [][]byte{data}
+		if *lines {
+			payloads = [][]byte{}
+			for _, line := range bytes.Split(data, []byte("\n")) {
+				line = bytes.TrimSuffix(line, []byte("\r"))
+				if len(line) > 0 {
+					payloads = append(payloads, line)
+				}
+			}
+		}
+
+		input := &rochefort.AppendInput{}
+		for _, payload := range payloads {
+			input.AppendPayload = append(input.AppendPayload, &rochefort.Append{
+				Namespace: this.namespace,
+				Tags:      splitTags(*tags),
+				AllocSize: uint32(*alloc),
+				Data:      payload,
+			})
+		}
+
+		out, err := this.client.SetContext(context.Background(), input)
+		if err != nil {
+			return err
+		}
+		for _, offset := range out.Offset {
+			fmt.Fprintln(this.stdout, offset)
+		}
+		return nil
+	}
+}
+
+func (this *command) get() func() error {
+	this.outputFlags()
+
+	return func() error {
+		if this.flags.NArg() == 0 {
+			return errors.New("get: expected offsets as arguments")
+		}
+		offsets := []uint64{}
+		for _, arg := range this.flags.Args() {
+			offset, err := strconv.ParseUint(arg, 10, 64)
+			if err != nil {
+				return fmt.Errorf("get: invalid offset %q", arg)
+			}
+			offsets = append(offsets, offset)
+		}
+
+		data, err := this.client.GetMultiContext(context.Background(), this.namespace, offsets)
+		if err != nil {
+			return err
+		}
+		for i := range data {
+			if err := this.print(offsets[i], data[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func (this *command) modify() func() error {
+	offset := this.flags.Uint64("offset", 0, "offset of the record")
+	pos := this.flags.Uint("pos", 0, "position inside the record")
+	read := this.inputFlags()
+
+	return func() error {
+		if *pos > math.MaxInt32 {
+			return fmt.Errorf("modify: -pos %d is out of range, max %d", *pos, math.MaxInt32)
+		}
+		data, err := read()
+		if err != nil {
+			return err
+		}
+		modified, err := this.client.ModifyContext(context.Background(), this.namespace, *offset, uint32(*pos), data)
+		if err != nil {
+			return err
+		}
+		if !modified {
+			return fmt.Errorf("modify: record at %d was not modified", *offset)
+		}
+		return nil
+	}
+}
+
+func (this *command) scan() func() error {
+	this.outputFlags()
+
+	return func() error {
+		return this.client.ScanFuncContext(context.Background(), this.namespace, this.print)
+	}
+}
+
+// parseQuery accepts the json form (starting with {) or the query DSL, see rochefort.ParseQuery
+func parseQuery(input string) (interface{}, error) {
+	if strings.HasPrefix(strings.TrimSpace(input), "{") {
+		q := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(input), &q); err != nil {
+			return nil, err
+		}
+		return q, nil
+	}
+	return rochefort.ParseQuery(input)
+}
+
+func (this *command) search() func() error {
+	query := this.flags.String("query", "", `query, either json like {"tag":"a"} or DSL like "a and (b or not c)"`)
+	this.outputFlags()
+
+	return func() error {
+		q, err := parseQuery(*query)
+		if err != nil {
+			return fmt.Errorf("search: invalid query: %s", err.Error())
+		}
+		return this.client.SearchFuncContext(context.Background(), this.namespace, q, this.print)
+	}
+}
+
+func (this *command) stats() func() error {
+	return func() error {
+		stats, err := this.client.StatsContext(context.Background(), this.namespace)
+		if err != nil {
+			return err
+		}
+		e := json.NewEncoder(this.stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(stats)
+	}
+}
+
+func (this *command) compact() func() error {
+	return func() error {
+		_, err := this.client.CompactContext(context.Background(), &rochefort.NamespaceInput{Namespace: this.namespace})
+		return err
+	}
+}
+
+func (this *command) delete() func() error {
+	return func() error {
+		_, err := this.client.DeleteContext(context.Background(), &rochefort.NamespaceInput{Namespace: this.namespace})
+		return err
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func runCommand(t *testing.T, url string, stdin string, args ...string) string {
+	var stdout, stderr bytes.Buffer
+	err := run(append([]string{"-url", url}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	if err != nil {
+		t.Logf("%v: %s, stderr: %s", args, err, stderr.String())
+		t.FailNow()
+	}
+	return stdout.String()
+}
+
+func TestCommands(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	offsets := runCommand(t, server.URL, "aaa\nbbb\n", "set", "-ns", "cli", "-tags", "a", "-lines")
+	if offsets != "0\n19\n" {
+		t.Logf("unexpected offsets: %q", offsets)
+		t.FailNow()
+	}
+	runCommand(t, server.URL, "", "set", "-ns", "cli", "-tags", "b", "-alloc", "10", "-data", "ccc")
+
+	out := runCommand(t, server.URL, "", "get", "-ns", "cli", "19", "0")
+	if out != "bbb\naaa\n" {
+		t.Logf("unexpected get: %q", out)
+		t.FailNow()
+	}
+
+	runCommand(t, server.URL, "xyz", "modify", "-ns", "cli", "-offset", "38", "-pos", "3")
+
+	out = runCommand(t, server.URL, "", "scan", "-ns", "cli", "-offsets")
+	if out != "0\taaa\n19\tbbb\n38\tcccxyz\n" {
+		t.Logf("unexpected scan: %q", out)
+		t.FailNow()
+	}
+
+	out = runCommand(t, server.URL, "", "search", "-ns", "cli", "-query", "a and not b", "-format", "hex")
+	if out != "616161\n626262\n" {
+		t.Logf("unexpected search: %q", out)
+		t.FailNow()
+	}
+
+	out = runCommand(t, server.URL, "", "search", "-ns", "cli", "-query", `{"tag":"b"}`, "-format", "json")
+	if out != `{"offset":38,"data":"Y2NjeHl6"}`+"\n" {
+		t.Logf("unexpected search: %q", out)
+		t.FailNow()
+	}
+
+	out = runCommand(t, server.URL, "", "stats", "-ns", "cli")
+	if !strings.Contains(out, `"a": 2`) {
+		t.Logf("unexpected stats: %s", out)
+		t.FailNow()
+	}
+
+	runCommand(t, server.URL, "", "compact", "-ns", "cli")
+	runCommand(t, server.URL, "", "delete", "-ns", "cli")
+
+	var stdout, stderr bytes.Buffer
+	if run([]string{"-url", server.URL, "unknown"}, nil, &stdout, &stderr) == nil {
+		t.Log("expected error for unknown command")
+		t.FailNow()
+	}
+
+	for _, args := range [][]string{
+		{"set", "-ns", "cli", "-alloc", "4294967296", "-data", "x"},
+		{"modify", "-ns", "cli", "-offset", "0", "-pos", "2147483648", "-data", "x"},
+	} {
+		err := run(append([]string{"-url", server.URL}, args...), nil, &stdout, &stderr)
+		if err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Logf("%v: expected out of range error, got: %v", args, err)
+			t.FailNow()
+		}
+	}
+
+	requests := server.Requests("get") + server.Requests("scan") + server.Requests("query")
+	for _, args := range [][]string{
+		{"get", "-ns", "cli", "-format", "bogus", "0"},
+		{"scan", "-ns", "empty", "-format", "bogus"},
+		{"search", "-ns", "cli", "-format", "bogus", "-query", "a"},
+	} {
+		err := run(append([]string{"-url", server.URL}, args...), nil, &stdout, &stderr)
+		if err == nil || !strings.Contains(err.Error(), "unknown format") {
+			t.Logf("%v: expected unknown format error, got: %v", args, err)
+			t.FailNow()
+		}
+	}
+	if n := server.Requests("get") + server.Requests("scan") + server.Requests("query"); n != requests {
+		t.Logf("expected no requests with unknown format, got: %d", n-requests)
+		t.FailNow()
+	}
+}
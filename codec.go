@@ -0,0 +1,95 @@
+package rochefort
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrModifyUnsupported is returned by Set and Modify when the client has payload codecs, in place modification of encoded records would corrupt them
+var ErrModifyUnsupported = errors.New("modify is not supported with payload codecs")
+
+// PayloadCodec transforms the record data on the client, Encode is applied to the appended data and Decode to the data returned by Get, Scan and Search
+type PayloadCodec interface {
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+// RecordError is returned when a record read from the server can not be decoded by the payload codecs
+type RecordError struct {
+	Offset uint64
+	Err    error
+}
+
+func (this *RecordError) Error() string {
+	return fmt.Sprintf("failed to decode record at offset %d, error: %s", this.Offset, this.Err.Error())
+}
+
+func (this *RecordError) Unwrap() error {
+	return this.Err
+}
+
+// WithPayloadCodec adds payload codec to the client, the codecs encode in the order they are added and decode in reverse order
+// e.g. WithCompression followed by WithEncryption compresses before encrypting
+func WithPayloadCodec(codec PayloadCodec) Option {
+	return func(o *options) {
+		o.codecs = append(o.codecs, codec)
+	}
+}
+
+// encodeInput returns copy of the input with encoded appends
+func (this *Client) encodeInput(input *AppendInput) (*AppendInput, error) {
+	if len(this.codecs) == 0 {
+		return input, nil
+	}
+	if len(input.ModifyPayload) > 0 {
+		return nil, ErrModifyUnsupported
+	}
+
+	out := &AppendInput{
+		AppendPayload: make([]*Append, len(input.AppendPayload)),
+	}
+	for i, a := range input.AppendPayload {
+		data := a.Data
+		for _, codec := range this.codecs {
+			var err error
+			data, err = codec.Encode(data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		encoded := *a
+		encoded.Data = data
+		out.AppendPayload[i] = &encoded
+	}
+	return out, nil
+}
+
+// decode applies the codecs in reverse order, the errors are wrapped in *RecordError
+func (this *Client) decode(offset uint64, data []byte) ([]byte, error) {
+	for i := len(this.codecs) - 1; i >= 0; i-- {
+		var err error
+		data, err = this.codecs[i].Decode(data)
+		if err != nil {
+			return nil, &RecordError{Offset: offset, Err: err}
+		}
+	}
+	return data, nil
+}
+
+// raw returns copy of the client without payload codecs, used for the records managed by the package itself (e.g. consumer slots)
+func (this *Client) raw() *Client {
+	if len(this.codecs) == 0 {
+		return this
+	}
+	c := *this
+	c.codecs = nil
+	return &c
+}
+
+// rawOf is raw for any Interface, the other implementations have no codecs and are returned as is
+func rawOf(client Interface) Interface {
+	if c, ok := client.(*Client); ok {
+		return c.raw()
+	}
+	return client
+}
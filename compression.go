@@ -0,0 +1,172 @@
+package rochefort
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"sync"
+)
+
+// Compressor is a compression algorithm, identified by the id byte written in the header of every compressed record
+type Compressor interface {
+	// unique id written in the header, 0 is reserved for uncompressed data
+	ID() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	compressorsLock sync.RWMutex
+	compressors     = map[byte]Compressor{}
+)
+
+// RegisterCompressor makes the compressor available for decoding, it panics if the id is 0 or already registered
+// register third party algorithms (e.g. zstd or snappy) from init with ids above 16, the lower ones are reserved for this package
+func RegisterCompressor(c Compressor) {
+	compressorsLock.Lock()
+	defer compressorsLock.Unlock()
+
+	if c.ID() == 0 {
+		panic("rochefort: compressor id 0 is reserved for uncompressed data")
+	}
+	if _, ok := compressors[c.ID()]; ok {
+		panic(fmt.Sprintf("rochefort: compressor with id %d already registered", c.ID()))
+	}
+	compressors[c.ID()] = c
+}
+
+func lookupCompressor(id byte) (Compressor, bool) {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+
+	c, ok := compressors[id]
+	return c, ok
+}
+
+// the built-in compressors, registered by default
+var (
+	Gzip  Compressor = &gzipCompressor{level: gzip.DefaultCompression}
+	Flate Compressor = &flateCompressor{level: flate.DefaultCompression}
+)
+
+func init() {
+	RegisterCompressor(Gzip)
+	RegisterCompressor(Flate)
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (this *gzipCompressor) ID() byte {
+	return 1
+}
+
+func (this *gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, this.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (this *gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+type flateCompressor struct {
+	level int
+}
+
+func (this *flateCompressor) ID() byte {
+	return 2
+}
+
+func (this *flateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, this.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (this *flateCompressor) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// the header of the compressed records, followed by the compressor id
+var compressionMagic = []byte{0xfe, 'R', 'Z'}
+
+// CompressionCodec is PayloadCodec that compresses the data and prefixes it with 4 bytes header, 3 bytes magic and the id of the compressor
+// it decodes records written with any registered compressor, and returns the records without the header as they are,
+// so namespaces with mixed algorithms and records written before the compression was enabled still decode
+type CompressionCodec struct {
+	// the compressor used for encoding, nil stores the data uncompressed
+	Compressor Compressor
+	// data smaller than this is stored uncompressed
+	MinSize int
+}
+
+func (this *CompressionCodec) Encode(data []byte) ([]byte, error) {
+	if this.Compressor == nil || len(data) < this.MinSize {
+		// uncompressed data is stored as it is, unless it could be mistaken for compressed record
+		if bytes.HasPrefix(data, compressionMagic) {
+			return append(append(append([]byte{}, compressionMagic...), 0), data...), nil
+		}
+		return data, nil
+	}
+
+	compressed, err := this.Compressor.Compress(data)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(compressionMagic)+1+len(compressed))
+	out = append(out, compressionMagic...)
+	out = append(out, this.Compressor.ID())
+	return append(out, compressed...), nil
+}
+
+func (this *CompressionCodec) Decode(data []byte) ([]byte, error) {
+	if len(data) <= len(compressionMagic) || !bytes.HasPrefix(data, compressionMagic) {
+		return data, nil
+	}
+
+	id := data[len(compressionMagic)]
+	data = data[len(compressionMagic)+1:]
+	if id == 0 {
+		return data, nil
+	}
+	c, ok := lookupCompressor(id)
+	if !ok {
+		return nil, fmt.Errorf("unknown compressor id %d", id)
+	}
+	return c.Decompress(data)
+}
+
+// WithCompression compresses the appended data with the compressor, see CompressionCodec
+func WithCompression(c Compressor) Option {
+	return WithPayloadCodec(&CompressionCodec{Compressor: c})
+}
@@ -0,0 +1,130 @@
+package rochefort_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+type reverseCompressor struct{}
+
+func (reverseCompressor) ID() byte {
+	return 200
+}
+
+func (reverseCompressor) Compress(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[len(data)-1-i] = data[i]
+	}
+	return out, nil
+}
+
+func (this reverseCompressor) Decompress(data []byte) ([]byte, error) {
+	return this.Compress(data)
+}
+
+func init() {
+	rochefort.RegisterCompressor(reverseCompressor{})
+}
+
+func TestCompression(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	raw := rochefort.NewClient(server.URL, nil)
+	value := bytes.Repeat([]byte("compress me "), 100)
+
+	offsets := []uint64{}
+	for _, c := range []rochefort.Compressor{rochefort.Gzip, rochefort.Flate, reverseCompressor{}, nil} {
+		r := rochefort.New(server.URL, rochefort.WithCompression(c))
+		off, err := r.Append("compression", nil, 0, value)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		offsets = append(offsets, off)
+
+		stored, err := raw.GetOne("compression", off)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if c == nil {
+			if !bytes.Equal(stored, value) {
+				t.Log("expected uncompressed record without header")
+				t.FailNow()
+			}
+			continue
+		}
+		if !bytes.HasPrefix(stored, []byte{0xfe, 'R', 'Z', c.ID()}) {
+			t.Logf("unexpected header: %v, expected id: %d", stored[:4], c.ID())
+			t.FailNow()
+		}
+	}
+
+	// records written before the compression was enabled, and uncompressed data that looks like the header
+	legacy := [][]byte{[]byte(`{"json":true}`), {0, 1, 2}, {0xfe, 'R', 'Z', 1, 2}}
+	for _, data := range legacy {
+		w := raw
+		if data[0] == 0xfe {
+			w = rochefort.New(server.URL, rochefort.WithCompression(nil))
+		}
+		off, err := w.Append("compression", nil, 0, data)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		offsets = append(offsets, off)
+	}
+	values := [][]byte{value, value, value, value}
+	values = append(values, legacy...)
+
+	// a client with any compressor decodes all of them
+	r := rochefort.New(server.URL, rochefort.WithCompression(rochefort.Flate))
+	many, err := r.GetMulti("compression", offsets)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	for i, data := range many {
+		if !bytes.Equal(data, values[i]) {
+			t.Logf("decoded %q != %q", data, values[i])
+			t.FailNow()
+		}
+	}
+
+	scanned := 0
+	err = r.Scan("compression", func(offset uint64, data []byte) {
+		if !bytes.Equal(data, values[scanned]) {
+			t.Logf("scanned %q != %q", data, values[scanned])
+			t.FailNow()
+		}
+		scanned++
+	})
+	if err != nil || scanned != len(offsets) {
+		t.Logf("unexpected scan: %d, %v", scanned, err)
+		t.FailNow()
+	}
+
+	_, err = r.Modify("compression", offsets[0], 0, []byte("x"))
+	if err != rochefort.ErrModifyUnsupported {
+		t.Logf("expected ErrModifyUnsupported, got: %v", err)
+		t.FailNow()
+	}
+
+	bad, err := raw.Append("compression", nil, 0, []byte{0xfe, 'R', 'Z', 99, 1, 2, 3})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	_, err = r.GetOne("compression", bad)
+	var recordErr *rochefort.RecordError
+	if !errors.As(err, &recordErr) || recordErr.Offset != bad {
+		t.Logf("expected RecordError, got: %v", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,201 @@
+package rochefort
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// ConsumerOffsetsNamespace is the default namespace where the consumers keep their committed positions
+const ConsumerOffsetsNamespace = "__consumer_offsets"
+
+// every consumer has one preallocated record (slot) in the offsets namespace, tagged with its group and namespace
+// the slot is 1 byte version and 8 bytes little endian position, and it is updated in place with Modify
+const (
+	consumerSlotVersion = 1
+	consumerSlotSize    = 9
+)
+
+// ConsumerConfig controls the consumer
+type ConsumerConfig struct {
+	// how the namespace is polled, StartAtEnd applies only when the group has no committed position yet, in which case Resume commits the end position
+	Follow FollowConfig
+	// where the positions are stored, default ConsumerOffsetsNamespace
+	OffsetsNamespace string
+	// do not commit after every processed record, the caller commits with Commit
+	ManualCommit bool
+}
+
+// Consumer is a named consumer (group) of a namespace, its position is committed in rochefort itself, so a restarted consumer continues where the previous one left off
+// the records are processed at least once: a record processed but not yet committed is processed again after restart
+type Consumer struct {
+	client    Interface
+	group     string
+	namespace string
+	config    ConsumerConfig
+	follower  *Follower
+	slot      uint64
+	resumed   bool
+	committed uint64
+}
+
+// NewConsumer creates consumer of the namespace for the group, call Resume or Run to load the committed position
+func NewConsumer(client Interface, group string, namespace string, config ConsumerConfig) *Consumer {
+	if config.OffsetsNamespace == "" {
+		config.OffsetsNamespace = ConsumerOffsetsNamespace
+	}
+	return &Consumer{
+		// the slots are modified in place, so they bypass the payload codecs
+		client:    rawOf(client),
+		group:     group,
+		namespace: namespace,
+		config:    config,
+		follower:  NewFollower(client, namespace, config.Follow),
+	}
+}
+
+func (this *Consumer) tag() string {
+	return fmt.Sprintf("consumer/%s/%s", this.group, this.namespace)
+}
+
+// findSlot returns the first slot of the consumer, the slot with the lowest offset wins if more than one was created concurrently
+func (this *Consumer) findSlot(ctx context.Context) (uint64, []byte, bool, error) {
+	var slot uint64
+	var data []byte
+	found := false
+	err := this.client.SearchFuncContext(ctx, this.config.OffsetsNamespace, Tag(this.tag()), func(offset uint64, value []byte) error {
+		slot = offset
+		data = value
+		found = true
+		return ErrStop
+	})
+	return slot, data, found, err
+}
+
+// Resume loads the committed position of the group, creating its slot if needed
+func (this *Consumer) Resume(ctx context.Context) error {
+	slot, data, found, err := this.findSlot(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		_, err = this.client.AppendContext(ctx, this.config.OffsetsNamespace, []string{this.tag()}, consumerSlotSize, encodeConsumerSlot(0, false))
+		if err != nil {
+			return err
+		}
+		// search again instead of using the appended offset, in case another worker created slot at the same time
+		slot, data, found, err = this.findSlot(ctx)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("consumer slot %s not found after creating it", this.tag())
+		}
+	}
+
+	position, committed, err := decodeConsumerSlot(data)
+	if err != nil {
+		return err
+	}
+
+	this.slot = slot
+	if !committed && this.config.Follow.StartAtEnd {
+		// commit the end position before anything is emitted, so a restart does not skip the records appended meanwhile
+		stats, err := this.client.StatsContext(ctx, this.namespace)
+		if err != nil {
+			return err
+		}
+		position = stats.Offset
+		if err := this.writeSlot(ctx, position); err != nil {
+			return err
+		}
+		committed = true
+	}
+	this.resumed = true
+	this.committed = position
+	if committed {
+		this.follower.Seek(position)
+	}
+	return nil
+}
+
+// Commit marks the record at rochefortOffset (and everything before it) as processed
+func (this *Consumer) Commit(ctx context.Context, rochefortOffset uint64) error {
+	if !this.resumed {
+		if err := this.Resume(ctx); err != nil {
+			return err
+		}
+	}
+
+	position := rochefortOffset + 1
+	if err := this.writeSlot(ctx, position); err != nil {
+		return err
+	}
+	this.committed = position
+	return nil
+}
+
+// writeSlot stores the committed position in the slot of the consumer
+func (this *Consumer) writeSlot(ctx context.Context, position uint64) error {
+	modified, err := this.client.ModifyContext(ctx, this.config.OffsetsNamespace, this.slot, 0, encodeConsumerSlot(position, true))
+	if err != nil {
+		return err
+	}
+	if !modified {
+		return fmt.Errorf("consumer slot %s at %d was not modified", this.tag(), this.slot)
+	}
+	return nil
+}
+
+// Committed returns the committed position, the rochefortOffset from which the consumer continues after restart
+func (this *Consumer) Committed() uint64 {
+	return this.committed
+}
+
+// Run resumes from the committed position and calls the callback for every record until ctx is done, see Follower.Follow
+// unless ManualCommit is set every record is committed after the callback returns nil (or ErrStop)
+func (this *Consumer) Run(ctx context.Context, callback func(rochefortOffset uint64, value []byte) error) error {
+	if !this.resumed {
+		if err := this.Resume(ctx); err != nil {
+			return err
+		}
+	}
+
+	return this.follower.Follow(ctx, func(offset uint64, data []byte) error {
+		err := callback(offset, data)
+		if err != nil && err != ErrStop {
+			return err
+		}
+		if !this.config.ManualCommit {
+			if commitErr := this.Commit(ctx, offset); commitErr != nil {
+				return commitErr
+			}
+		}
+		return err
+	})
+}
+
+func encodeConsumerSlot(position uint64, committed bool) []byte {
+	data := make([]byte, consumerSlotSize)
+	if committed {
+		data[0] = consumerSlotVersion
+	}
+	binary.LittleEndian.PutUint64(data[1:], position)
+	return data
+}
+
+// decodeConsumerSlot returns the position and whether it was ever committed
+func decodeConsumerSlot(data []byte) (uint64, bool, error) {
+	if len(data) != consumerSlotSize {
+		return 0, false, fmt.Errorf("expected consumer slot of %d bytes, but got: %d", consumerSlotSize, len(data))
+	}
+	switch data[0] {
+	case 0:
+		return 0, false, nil
+	case consumerSlotVersion:
+		return binary.LittleEndian.Uint64(data[1:]), true, nil
+	default:
+		return 0, false, fmt.Errorf("unknown consumer slot version %d", data[0])
+	}
+}
@@ -0,0 +1,168 @@
+package rochefort_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestConsumerResume(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	for _, v := range []string{"a", "b", "c", "d"} {
+		_, err := r.Append("events", nil, 0, []byte(v))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	config := rochefort.ConsumerConfig{Follow: rochefort.FollowConfig{Interval: time.Millisecond}}
+	failed := errors.New("worker crashed")
+
+	// the first worker processes a and b, and crashes on c
+	consumed := []string{}
+	c := rochefort.NewConsumer(r, "workers", "events", config)
+	err := c.Run(context.Background(), func(offset uint64, data []byte) error {
+		if string(data) == "c" {
+			return failed
+		}
+		consumed = append(consumed, string(data))
+		return nil
+	})
+	if err != failed {
+		t.Logf("expected the callback error, got: %v", err)
+		t.FailNow()
+	}
+
+	// the restarted worker continues from c
+	c = rochefort.NewConsumer(r, "workers", "events", config)
+	err = c.Run(context.Background(), func(offset uint64, data []byte) error {
+		consumed = append(consumed, string(data))
+		if string(data) == "d" {
+			return rochefort.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	expected := []string{"a", "b", "c", "d"}
+	if len(consumed) != len(expected) {
+		t.Logf("unexpected consumed records: %v", consumed)
+		t.FailNow()
+	}
+	for i := range expected {
+		if consumed[i] != expected[i] {
+			t.Logf("unexpected consumed records: %v", consumed)
+			t.FailNow()
+		}
+	}
+
+	// other groups have their own position
+	other := rochefort.NewConsumer(r, "audit", "events", config)
+	err = other.Resume(context.Background())
+	if err != nil || other.Committed() != 0 {
+		t.Logf("expected new group to start from 0, got: %d, %v", other.Committed(), err)
+		t.FailNow()
+	}
+
+	stats, err := r.Stats(rochefort.ConsumerOffsetsNamespace)
+	if err != nil || len(stats.Tags) != 2 {
+		t.Logf("expected 2 consumer slots, got: %v, %v", stats, err)
+		t.FailNow()
+	}
+}
+
+func TestConsumerManualCommit(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	offsets := []uint64{}
+	for _, v := range []string{"a", "b"} {
+		off, err := r.Append("events", nil, 0, []byte(v))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		offsets = append(offsets, off)
+	}
+
+	config := rochefort.ConsumerConfig{ManualCommit: true}
+	c := rochefort.NewConsumer(r, "manual", "events", config)
+	err := c.Commit(context.Background(), offsets[0])
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	c = rochefort.NewConsumer(r, "manual", "events", config)
+	err = c.Resume(context.Background())
+	if err != nil || c.Committed() != offsets[0]+1 {
+		t.Logf("unexpected committed position: %d, %v", c.Committed(), err)
+		t.FailNow()
+	}
+
+	err = c.Run(context.Background(), func(offset uint64, data []byte) error {
+		if string(data) != "b" {
+			t.Logf("unexpected record: %s", string(data))
+			t.FailNow()
+		}
+		return rochefort.ErrStop
+	})
+	if err != nil || c.Committed() != offsets[0]+1 {
+		t.Logf("expected Run not to commit, got: %d, %v", c.Committed(), err)
+		t.FailNow()
+	}
+}
+
+func TestConsumerStartAtEndRestart(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	_, err := r.Append("events", nil, 0, []byte("old"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	config := rochefort.ConsumerConfig{Follow: rochefort.FollowConfig{Interval: time.Millisecond, StartAtEnd: true}}
+
+	// the first worker takes the end position and stops before any record arrives
+	c := rochefort.NewConsumer(r, "tail", "events", config)
+	err = c.Resume(context.Background())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// the record appended while no worker runs is delivered after restart
+	_, err = r.Append("events", nil, 0, []byte("gap"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	seen := []string{}
+	c = rochefort.NewConsumer(r, "tail", "events", config)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = c.Run(ctx, func(offset uint64, data []byte) error {
+		seen = append(seen, string(data))
+		return rochefort.ErrStop
+	})
+	if err != nil || len(seen) != 1 || seen[0] != "gap" {
+		t.Logf("expected the record appended during the restart, got: %v, %v", seen, err)
+		t.FailNow()
+	}
+}
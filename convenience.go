@@ -0,0 +1,107 @@
+package rochefort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Append to the rochefort service, returns stored offset and error. in case of error the returned offset is 0, keep in mind that 0 is valid offset, so check the error field
+// allocSize parameter is used if you want to allocate more space than your data, so you can inplace modify it; can be 0
+// the tags parameter is used to build online inverted index that can be used from Search()
+func (this *Client) Append(namespace string, tags []string, allocSize uint32, data []byte) (uint64, error) {
+	return this.AppendContext(context.Background(), namespace, tags, allocSize, data)
+}
+
+// AppendContext is like Append, but the request is bound to ctx
+func (this *Client) AppendContext(ctx context.Context, namespace string, tags []string, allocSize uint32, data []byte) (uint64, error) {
+	out, err := this.SetContext(ctx, &AppendInput{
+		AppendPayload: []*Append{{
+			Namespace: namespace,
+			Tags:      tags,
+			AllocSize: allocSize,
+			Data:      data,
+		}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(out.Offset) != 1 {
+		return 0, fmt.Errorf("expected 1 offset, but got: %d", len(out.Offset))
+	}
+	return out.Offset[0], nil
+}
+
+// GetOne fetches single record from rochefort, use the offset returned by Append
+func (this *Client) GetOne(namespace string, offset uint64) ([]byte, error) {
+	return this.GetOneContext(context.Background(), namespace, offset)
+}
+
+// GetOneContext is like GetOne, but the request is bound to ctx
+func (this *Client) GetOneContext(ctx context.Context, namespace string, offset uint64) ([]byte, error) {
+	data, err := this.GetMultiContext(ctx, namespace, []uint64{offset})
+	if err != nil {
+		return nil, err
+	}
+	return data[0], nil
+}
+
+// GetMulti fetches multiple records from the same namespace in one round trip, the values are in the same order as the offsets
+func (this *Client) GetMulti(namespace string, offsets []uint64) ([][]byte, error) {
+	return this.GetMultiContext(context.Background(), namespace, offsets)
+}
+
+// GetMultiContext is like GetMulti, but the request is bound to ctx
+func (this *Client) GetMultiContext(ctx context.Context, namespace string, offsets []uint64) ([][]byte, error) {
+	input := &GetInput{
+		GetPayload: make([]*Get, len(offsets)),
+	}
+	for i, offset := range offsets {
+		input.GetPayload[i] = &Get{
+			Namespace: namespace,
+			Offset:    offset,
+		}
+	}
+
+	data, err := this.GetContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != len(offsets) {
+		return nil, fmt.Errorf("expected %d records, but got: %d", len(offsets), len(data))
+	}
+	return data, nil
+}
+
+// Modify overwrites the record at offset starting from position, the record must have enough allocated space (see allocSize of Append), otherwise the server rejects it
+// and the returned error is *ModifyError matching ErrOutOfBounds, the record is left untouched
+// returns true if the record was modified
+func (this *Client) Modify(namespace string, offset uint64, position uint32, data []byte) (bool, error) {
+	return this.ModifyContext(context.Background(), namespace, offset, position, data)
+}
+
+// ModifyContext is like Modify, but the request is bound to ctx
+func (this *Client) ModifyContext(ctx context.Context, namespace string, offset uint64, position uint32, data []byte) (bool, error) {
+	if position > math.MaxInt32 {
+		return false, fmt.Errorf("position %d is too big", position)
+	}
+
+	out, err := this.SetContext(ctx, &AppendInput{
+		ModifyPayload: []*Modify{{
+			Namespace: namespace,
+			Offset:    offset,
+			Pos:       int32(position),
+			Data:      data,
+		}},
+	})
+	if err != nil {
+		// the request has only the modification, so a rejection by the server is about it, not found, timeout and rate limit are not
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.Code >= 400 && statusErr.Code < 500 && statusErr.Code != 404 && statusErr.Code != 408 && statusErr.Code != 429 {
+			return false, &ModifyError{Offset: offset, Position: position, Err: statusErr}
+		}
+		return false, err
+	}
+	return out.ModifiedCount == 1, nil
+}
@@ -0,0 +1,96 @@
+package rochefort_test
+
+import (
+	"errors"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestConvenience(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	first, err := r.Append("convenience", []string{"a"}, 5, []byte("abc"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	second, err := r.Append("convenience", nil, 0, []byte("zxc"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	modified, err := r.Modify("convenience", first, 1, []byte("zxcv"))
+	if err != nil || !modified {
+		t.Logf("unexpected modify result: %v, %v", modified, err)
+		t.FailNow()
+	}
+
+	data, err := r.GetOne("convenience", first)
+	if err != nil || string(data) != "azxcv" {
+		t.Logf("unexpected read: %s, %v", string(data), err)
+		t.FailNow()
+	}
+
+	many, err := r.GetMulti("convenience", []uint64{second, first})
+	if err != nil || len(many) != 2 || string(many[0]) != "zxc" || string(many[1]) != "azxcv" {
+		t.Logf("unexpected multi read: %q, %v", many, err)
+		t.FailNow()
+	}
+
+	modified, err = r.Modify("convenience", second, 1, []byte("zxcv"))
+	var modifyErr *rochefort.ModifyError
+	if !errors.Is(err, rochefort.ErrOutOfBounds) || !errors.As(err, &modifyErr) || modifyErr.Offset != second || modified {
+		t.Logf("expected ErrOutOfBounds, got: %v, %v", modified, err)
+		t.FailNow()
+	}
+
+	_, err = r.GetOne("convenience", second+1)
+	if !errors.Is(err, rochefort.ErrNotFound) {
+		t.Logf("expected ErrNotFound, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestNamespaceEscaping(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	for _, ns := range []string{"a&b=c", "a#b", "a+b", "a b"} {
+		_, err := r.Append(ns, []string{"t"}, 0, []byte(ns))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		// a namespace changed by the url would be another, empty one
+		stats, err := r.Stats(ns)
+		if err != nil || stats.Tags["t"] != 1 {
+			t.Logf("%q: unexpected stats: %v, %v", ns, stats, err)
+			t.FailNow()
+		}
+
+		scanned := []string{}
+		err = r.Scan(ns, func(offset uint64, data []byte) {
+			scanned = append(scanned, string(data))
+		})
+		if err != nil || len(scanned) != 1 || scanned[0] != ns {
+			t.Logf("%q: unexpected scan: %v, %v", ns, scanned, err)
+			t.FailNow()
+		}
+
+		found := []string{}
+		err = r.Search(ns, rochefort.Tag("t"), func(offset uint64, data []byte) {
+			found = append(found, string(data))
+		})
+		if err != nil || len(found) != 1 || found[0] != ns {
+			t.Logf("%q: unexpected search: %v, %v", ns, found, err)
+			t.FailNow()
+		}
+	}
+}
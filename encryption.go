@@ -0,0 +1,161 @@
+package rochefort
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrUnknownKey is returned by KeyProvider when it does not have key with the requested id
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// KeyProvider supplies the AES keys (16, 24 or 32 bytes) for EncryptionCodec, the key id is stored with every record so the keys can be rotated
+type KeyProvider interface {
+	// CurrentKey returns the key used to encrypt new records and its id (at most 255 bytes)
+	CurrentKey() (id string, key []byte, err error)
+	// Key returns the key with the given id, used to decrypt the records
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is KeyProvider with in memory set of keys, keep the old keys as long as there are records encrypted with them
+// set the fields only before the client is in use, afterwards rotate the keys with Rotate, AddKey and RemoveKey, which are safe for concurrent use
+type StaticKeys struct {
+	Current string
+	Keys    map[string][]byte
+
+	lock sync.RWMutex
+}
+
+func (this *StaticKeys) CurrentKey() (string, []byte, error) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	key, ok := this.Keys[this.Current]
+	if !ok {
+		return "", nil, ErrUnknownKey
+	}
+	return this.Current, key, nil
+}
+
+func (this *StaticKeys) Key(id string) ([]byte, error) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	key, ok := this.Keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// AddKey adds key that can decrypt records, without using it for the new ones
+func (this *StaticKeys) AddKey(id string, key []byte) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.Keys == nil {
+		this.Keys = map[string][]byte{}
+	}
+	this.Keys[id] = key
+}
+
+// Rotate adds the key and encrypts the new records with it
+func (this *StaticKeys) Rotate(id string, key []byte) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.Keys == nil {
+		this.Keys = map[string][]byte{}
+	}
+	this.Keys[id] = key
+	this.Current = id
+}
+
+// RemoveKey forgets the key, the records encrypted with it fail to decode with ErrUnknownKey
+func (this *StaticKeys) RemoveKey(id string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	delete(this.Keys, id)
+}
+
+const encryptionVersion = 1
+
+// EncryptionCodec is PayloadCodec that encrypts the data with AES-GCM, the tags are not encrypted because the server needs them for the index
+// the record is: 1 byte version, 1 byte key id length, key id, nonce and the sealed data; the version and the key id are authenticated as well
+// in place Modify of encrypted records is refused with ErrModifyUnsupported
+type EncryptionCodec struct {
+	Keys KeyProvider
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (this *EncryptionCodec) Encode(data []byte) ([]byte, error) {
+	id, key, err := this.Keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, fmt.Errorf("key id is too long: %d bytes", len(id))
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, 2+len(id)+gcm.NonceSize()+len(data)+gcm.Overhead())
+	header = append(header, encryptionVersion, byte(len(id)))
+	header = append(header, id...)
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(header, nonce...)
+	return gcm.Seal(out, nonce, data, header), nil
+}
+
+func (this *EncryptionCodec) Decode(data []byte) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, errors.New("encrypted record is too short")
+	}
+	if data[0] != encryptionVersion {
+		return nil, fmt.Errorf("unknown encryption version %d", data[0])
+	}
+	idEnd := 2 + int(data[1])
+	if len(data) < idEnd {
+		return nil, errors.New("encrypted record is too short")
+	}
+	header := data[:idEnd]
+
+	key, err := this.Keys.Key(string(data[2:idEnd]))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < idEnd+gcm.NonceSize()+gcm.Overhead() {
+		return nil, errors.New("encrypted record is too short")
+	}
+	nonce := data[idEnd : idEnd+gcm.NonceSize()]
+	return gcm.Open(nil, nonce, data[idEnd+gcm.NonceSize():], header)
+}
+
+// WithEncryption encrypts the appended data with the keys of the provider, see EncryptionCodec
+func WithEncryption(keys KeyProvider) Option {
+	return WithPayloadCodec(&EncryptionCodec{Keys: keys})
+}
@@ -0,0 +1,103 @@
+package rochefort_test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestEncryption(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	raw := rochefort.NewClient(server.URL, nil)
+	keys := &rochefort.StaticKeys{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	}
+	r := rochefort.New(server.URL, rochefort.WithCompression(rochefort.Gzip), rochefort.WithEncryption(keys))
+
+	secret := []byte("john.doe@example.com")
+	first, err := r.Append("pii", []string{"user"}, 0, secret)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	stored, err := raw.GetOne("pii", first)
+	if err != nil || bytes.Contains(stored, secret) {
+		t.Logf("expected encrypted record, got: %q, %v", stored, err)
+		t.FailNow()
+	}
+
+	// rotate the key while other goroutines are writing, the old records still decrypt
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.Append("concurrent", nil, 0, secret); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	keys.Rotate("k2", bytes.Repeat([]byte{2}, 16))
+	wg.Wait()
+
+	second, err := r.Append("pii", []string{"user"}, 0, secret)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	found := 0
+	err = r.Search("pii", rochefort.Tag("user"), func(offset uint64, data []byte) {
+		if !bytes.Equal(data, secret) {
+			t.Logf("unexpected decrypted data: %q", data)
+			t.FailNow()
+		}
+		found++
+	})
+	if err != nil || found != 2 {
+		t.Logf("unexpected search: %d, %v", found, err)
+		t.FailNow()
+	}
+
+	_, err = r.Modify("pii", first, 0, []byte("x"))
+	if err != rochefort.ErrModifyUnsupported {
+		t.Logf("expected ErrModifyUnsupported, got: %v", err)
+		t.FailNow()
+	}
+
+	// tampering is detected
+	stored, err = raw.GetOne("pii", second)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	stored[len(stored)-1] ^= 1
+	tampered, err := raw.Append("pii", nil, 0, stored)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	_, err = r.GetOne("pii", tampered)
+	var recordErr *rochefort.RecordError
+	if !errors.As(err, &recordErr) {
+		t.Logf("expected RecordError for tampered record, got: %v", err)
+		t.FailNow()
+	}
+
+	keys.RemoveKey("k1")
+	_, err = r.GetOne("pii", first)
+	if !errors.Is(err, rochefort.ErrUnknownKey) {
+		t.Logf("expected ErrUnknownKey, got: %v", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,318 @@
+package rochefort
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"time"
+)
+
+// ErrCorrupt matches (with errors.Is) the errors of records that look like envelopes but can not be decoded
+var ErrCorrupt = errors.New("corrupt record")
+
+// ChecksumError is returned when the CRC32C of the envelope does not match its content
+type ChecksumError struct {
+	Expected uint32
+	Actual   uint32
+}
+
+func (this *ChecksumError) Error() string {
+	return fmt.Sprintf("envelope checksum mismatch, expected: %08x, actual: %08x", this.Expected, this.Actual)
+}
+
+func (this *ChecksumError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+var envelopeMagic = []byte{0xfe, 'R', 'E'}
+
+const envelopeVersion = 1
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Envelope is record with metadata, encoded as:
+// 3 bytes magic, 1 byte version, 8 bytes little endian unix nano timestamp, content type, headers, data and 4 bytes little endian CRC32C of everything before it
+// the strings, the headers and the data are prefixed with their uvarint length
+type Envelope struct {
+	Timestamp   time.Time
+	ContentType string
+	Headers     map[string]string
+	Data        []byte
+}
+
+// IsEnvelope reports whether the record starts with the envelope magic, raw records that start with the same bytes can not be told apart
+func IsEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, envelopeMagic)
+}
+
+func appendBytes(out []byte, b []byte) []byte {
+	out = binary.AppendUvarint(out, uint64(len(b)))
+	return append(out, b...)
+}
+
+// Marshal encodes the envelope
+func (this *Envelope) Marshal() ([]byte, error) {
+	out := make([]byte, 0, len(envelopeMagic)+1+8+len(this.ContentType)+len(this.Data)+32)
+	out = append(out, envelopeMagic...)
+	out = append(out, envelopeVersion)
+	out = binary.LittleEndian.AppendUint64(out, uint64(this.Timestamp.UnixNano()))
+	out = appendBytes(out, []byte(this.ContentType))
+	out = binary.AppendUvarint(out, uint64(len(this.Headers)))
+	for k, v := range this.Headers {
+		out = appendBytes(out, []byte(k))
+		out = appendBytes(out, []byte(v))
+	}
+	out = appendBytes(out, this.Data)
+	return binary.LittleEndian.AppendUint32(out, crc32.Checksum(out, castagnoli)), nil
+}
+
+type envelopeReader struct {
+	data []byte
+}
+
+func (this *envelopeReader) bytes() ([]byte, error) {
+	n, size := binary.Uvarint(this.data)
+	if size <= 0 || uint64(len(this.data)-size) < n {
+		return nil, fmt.Errorf("%w: truncated envelope", ErrCorrupt)
+	}
+	out := this.data[size : size+int(n)]
+	this.data = this.data[size+int(n):]
+	return out, nil
+}
+
+// UnmarshalEnvelope decodes and verifies the envelope, the error matches ErrCorrupt if the record is not valid envelope
+func UnmarshalEnvelope(data []byte) (*Envelope, error) {
+	if !IsEnvelope(data) {
+		return nil, fmt.Errorf("%w: missing envelope magic", ErrCorrupt)
+	}
+	if len(data) < len(envelopeMagic)+1+8+4 {
+		return nil, fmt.Errorf("%w: truncated envelope", ErrCorrupt)
+	}
+
+	body := data[:len(data)-4]
+	expected := binary.LittleEndian.Uint32(data[len(data)-4:])
+	if actual := crc32.Checksum(body, castagnoli); actual != expected {
+		return nil, &ChecksumError{Expected: expected, Actual: actual}
+	}
+
+	if version := body[len(envelopeMagic)]; version != envelopeVersion {
+		return nil, fmt.Errorf("%w: unknown envelope version %d", ErrCorrupt, version)
+	}
+	r := &envelopeReader{data: body[len(envelopeMagic)+1:]}
+
+	e := &Envelope{
+		Timestamp: time.Unix(0, int64(binary.LittleEndian.Uint64(r.data))),
+	}
+	r.data = r.data[8:]
+
+	contentType, err := r.bytes()
+	if err != nil {
+		return nil, err
+	}
+	e.ContentType = string(contentType)
+
+	count, size := binary.Uvarint(r.data)
+	if size <= 0 || count > uint64(len(r.data)) {
+		return nil, fmt.Errorf("%w: truncated envelope", ErrCorrupt)
+	}
+	r.data = r.data[size:]
+	if count > 0 {
+		e.Headers = make(map[string]string, count)
+	}
+	for i := uint64(0); i < count; i++ {
+		k, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.bytes()
+		if err != nil {
+			return nil, err
+		}
+		e.Headers[string(k)] = string(v)
+	}
+
+	e.Data, err = r.bytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(r.data) != 0 {
+		return nil, fmt.Errorf("%w: %d trailing bytes in envelope", ErrCorrupt, len(r.data))
+	}
+	return e, nil
+}
+
+// EnvelopeCodec is PayloadCodec that wraps the appended data in Envelope, and verifies and unwraps it on read
+// records without the envelope magic are returned as is, so enveloped and raw records coexist in the same namespace
+// to read the metadata use GetEnvelope or ScanEnvelopes, to write per record metadata use AppendEnvelope
+type EnvelopeCodec struct {
+	ContentType string
+	Headers     map[string]string
+	// returns the timestamp of the new records, default time.Now
+	Now func() time.Time
+}
+
+func (this *EnvelopeCodec) Encode(data []byte) ([]byte, error) {
+	now := time.Now
+	if this.Now != nil {
+		now = this.Now
+	}
+	e := &Envelope{
+		Timestamp:   now(),
+		ContentType: this.ContentType,
+		Headers:     this.Headers,
+		Data:        data,
+	}
+	return e.Marshal()
+}
+
+func (this *EnvelopeCodec) Decode(data []byte) ([]byte, error) {
+	if !IsEnvelope(data) {
+		return data, nil
+	}
+	e, err := UnmarshalEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	return e.Data, nil
+}
+
+// WithEnvelope wraps the appended data in Envelope with the content type and the headers, see EnvelopeCodec
+func WithEnvelope(contentType string, headers map[string]string) Option {
+	return WithPayloadCodec(&EnvelopeCodec{ContentType: contentType, Headers: headers})
+}
+
+// AppendEnvelope appends the record with its own envelope metadata, the codecs added before the EnvelopeCodec are applied to the Data and the ones after it to the marshalled envelope
+// zero Timestamp, empty ContentType and the missing headers are taken from the EnvelopeCodec of the client, without EnvelopeCodec the envelope is marshalled after all the codecs
+func (this *Client) AppendEnvelope(namespace string, tags []string, e *Envelope) (uint64, error) {
+	return this.AppendEnvelopeContext(context.Background(), namespace, tags, e)
+}
+
+// AppendEnvelopeContext is like AppendEnvelope, but the request is bound to ctx
+func (this *Client) AppendEnvelopeContext(ctx context.Context, namespace string, tags []string, e *Envelope) (uint64, error) {
+	data, err := this.encodeEnvelope(e)
+	if err != nil {
+		return 0, err
+	}
+	return this.raw().AppendContext(ctx, namespace, tags, 0, data)
+}
+
+// encodeEnvelope is the reverse of decodeEnvelope, it applies the codecs up to the EnvelopeCodec to the data, marshals the envelope and encodes it with the rest
+func (this *Client) encodeEnvelope(e *Envelope) ([]byte, error) {
+	envelope := *e
+	i := 0
+	for ; i < len(this.codecs); i++ {
+		codec, ok := this.codecs[i].(*EnvelopeCodec)
+		if ok {
+			envelope.fill(codec)
+			break
+		}
+		var err error
+		envelope.Data, err = this.codecs[i].Encode(envelope.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if envelope.Timestamp.IsZero() {
+		envelope.Timestamp = time.Now()
+	}
+
+	data, err := envelope.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	for i++; i < len(this.codecs); i++ {
+		data, err = this.codecs[i].Encode(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// fill sets the zero fields of the envelope to the defaults of the codec, the headers of the envelope take precedence
+func (this *Envelope) fill(codec *EnvelopeCodec) {
+	if this.Timestamp.IsZero() && codec.Now != nil {
+		this.Timestamp = codec.Now()
+	}
+	if this.ContentType == "" {
+		this.ContentType = codec.ContentType
+	}
+	if len(codec.Headers) > 0 {
+		headers := make(map[string]string, len(codec.Headers)+len(this.Headers))
+		for k, v := range codec.Headers {
+			headers[k] = v
+		}
+		for k, v := range this.Headers {
+			headers[k] = v
+		}
+		this.Headers = headers
+	}
+}
+
+// GetEnvelope fetches the record with its envelope metadata, the codecs added before the EnvelopeCodec are applied to the Data
+// records without envelope are returned with only the Data set
+func (this *Client) GetEnvelope(namespace string, offset uint64) (*Envelope, error) {
+	return this.GetEnvelopeContext(context.Background(), namespace, offset)
+}
+
+// GetEnvelopeContext is like GetEnvelope, but the request is bound to ctx
+func (this *Client) GetEnvelopeContext(ctx context.Context, namespace string, offset uint64) (*Envelope, error) {
+	data, err := this.raw().GetOneContext(ctx, namespace, offset)
+	if err != nil {
+		return nil, err
+	}
+	return this.decodeEnvelope(offset, data)
+}
+
+// ScanEnvelopes is like ScanFunc, but the records are returned with their envelope metadata, see GetEnvelope
+func (this *Client) ScanEnvelopes(namespace string, callback func(rochefortOffset uint64, e *Envelope) error) error {
+	return this.ScanEnvelopesContext(context.Background(), namespace, callback)
+}
+
+// ScanEnvelopesContext is like ScanEnvelopes, but the request is bound to ctx
+func (this *Client) ScanEnvelopesContext(ctx context.Context, namespace string, callback func(rochefortOffset uint64, e *Envelope) error) error {
+	return this.raw().ScanFuncContext(ctx, namespace, func(offset uint64, data []byte) error {
+		e, err := this.decodeEnvelope(offset, data)
+		if err != nil {
+			return err
+		}
+		return callback(offset, e)
+	})
+}
+
+// decodeEnvelope applies the codecs in reverse order up to the EnvelopeCodec, unmarshals the envelope and decodes its data with the rest
+// without EnvelopeCodec all codecs are applied before looking for the envelope
+func (this *Client) decodeEnvelope(offset uint64, data []byte) (*Envelope, error) {
+	i := len(this.codecs) - 1
+	for ; i >= 0; i-- {
+		if _, ok := this.codecs[i].(*EnvelopeCodec); ok {
+			break
+		}
+		var err error
+		data, err = this.codecs[i].Decode(data)
+		if err != nil {
+			return nil, &RecordError{Offset: offset, Err: err}
+		}
+	}
+
+	e := &Envelope{Data: data}
+	if IsEnvelope(data) {
+		var err error
+		e, err = UnmarshalEnvelope(data)
+		if err != nil {
+			return nil, &RecordError{Offset: offset, Err: err}
+		}
+	}
+
+	for i--; i >= 0; i-- {
+		var err error
+		e.Data, err = this.codecs[i].Decode(e.Data)
+		if err != nil {
+			return nil, &RecordError{Offset: offset, Err: err}
+		}
+	}
+	return e, nil
+}
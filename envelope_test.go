@@ -0,0 +1,218 @@
+package rochefort_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestEnvelopeMarshal(t *testing.T) {
+	e := &rochefort.Envelope{
+		Timestamp:   time.Unix(1500000000, 123),
+		ContentType: "application/json",
+		Headers:     map[string]string{"source": "test", "id": "1"},
+		Data:        []byte(`{"a":1}`),
+	}
+	data, err := e.Marshal()
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	decoded, err := rochefort.UnmarshalEnvelope(data)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if !decoded.Timestamp.Equal(e.Timestamp) || decoded.ContentType != e.ContentType || decoded.Headers["source"] != "test" || decoded.Headers["id"] != "1" || string(decoded.Data) != string(e.Data) {
+		t.Logf("unexpected envelope: %+v", decoded)
+		t.FailNow()
+	}
+
+	for i := range data {
+		corrupt := append([]byte{}, data...)
+		corrupt[i] ^= 0xff
+		_, err := rochefort.UnmarshalEnvelope(corrupt)
+		if !errors.Is(err, rochefort.ErrCorrupt) {
+			t.Logf("expected ErrCorrupt for flipped byte %d, got: %v", i, err)
+			t.FailNow()
+		}
+	}
+
+	_, err = rochefort.UnmarshalEnvelope(data[:len(data)-1])
+	if !errors.Is(err, rochefort.ErrCorrupt) {
+		t.Logf("expected ErrCorrupt for truncated envelope, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestEnvelopeCodec(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	raw := rochefort.NewClient(server.URL, nil)
+	r := rochefort.New(server.URL, rochefort.WithEnvelope("text/plain", map[string]string{"app": "test"}))
+
+	enveloped, err := r.Append("envelope", nil, 0, []byte("abc"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	_, err = raw.Append("envelope", nil, 0, []byte("raw"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	values := []string{}
+	err = r.Scan("envelope", func(offset uint64, data []byte) {
+		values = append(values, string(data))
+	})
+	if err != nil || len(values) != 2 || values[0] != "abc" || values[1] != "raw" {
+		t.Logf("unexpected scan: %v, %v", values, err)
+		t.FailNow()
+	}
+
+	stored, err := raw.GetOne("envelope", enveloped)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	e, err := rochefort.UnmarshalEnvelope(stored)
+	if err != nil || e.ContentType != "text/plain" || e.Headers["app"] != "test" || time.Since(e.Timestamp) > time.Minute {
+		t.Logf("unexpected envelope: %+v, %v", e, err)
+		t.FailNow()
+	}
+
+	stored[len(stored)-1] ^= 1
+	corrupt, err := raw.Append("envelope", nil, 0, stored)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	_, err = r.GetOne("envelope", corrupt)
+	var checksumErr *rochefort.ChecksumError
+	var recordErr *rochefort.RecordError
+	if !errors.As(err, &checksumErr) || !errors.As(err, &recordErr) || recordErr.Offset != corrupt || !errors.Is(err, rochefort.ErrCorrupt) {
+		t.Logf("expected ChecksumError, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestEnvelopeRead(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	raw := rochefort.NewClient(server.URL, nil)
+	r := rochefort.New(server.URL, rochefort.WithCompression(rochefort.Gzip), rochefort.WithEnvelope("text/plain", map[string]string{"app": "test"}))
+
+	enveloped, err := r.Append("read", nil, 0, []byte("abc"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	plain, err := raw.Append("read", nil, 0, []byte("raw"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	e, err := r.GetEnvelope("read", enveloped)
+	if err != nil || string(e.Data) != "abc" || e.ContentType != "text/plain" || e.Headers["app"] != "test" || time.Since(e.Timestamp) > time.Minute {
+		t.Logf("unexpected envelope: %+v, %v", e, err)
+		t.FailNow()
+	}
+
+	e, err = r.GetEnvelope("read", plain)
+	if err != nil || string(e.Data) != "raw" || e.ContentType != "" || !e.Timestamp.IsZero() {
+		t.Logf("unexpected envelope of raw record: %+v, %v", e, err)
+		t.FailNow()
+	}
+
+	envelopes := map[uint64]*rochefort.Envelope{}
+	err = r.ScanEnvelopes("read", func(offset uint64, e *rochefort.Envelope) error {
+		envelopes[offset] = e
+		return nil
+	})
+	if err != nil || len(envelopes) != 2 || string(envelopes[enveloped].Data) != "abc" || envelopes[enveloped].Headers["app"] != "test" || string(envelopes[plain].Data) != "raw" {
+		t.Logf("unexpected scan: %v, %v", envelopes, err)
+		t.FailNow()
+	}
+
+	stored, err := raw.GetOne("read", enveloped)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	stored[len(stored)-1] ^= 1
+	corrupt, err := raw.Append("read", nil, 0, stored)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	_, err = r.GetEnvelope("read", corrupt)
+	var recordErr *rochefort.RecordError
+	if !errors.As(err, &recordErr) || recordErr.Offset != corrupt || !errors.Is(err, rochefort.ErrCorrupt) {
+		t.Logf("expected ErrCorrupt, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestAppendEnvelope(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	r := rochefort.New(server.URL, rochefort.WithCompression(rochefort.Gzip), rochefort.WithEnvelope("text/plain", map[string]string{"app": "test", "id": "0"}))
+
+	timestamp := time.Unix(1500000000, 123)
+	offset, err := r.AppendEnvelope("write", []string{"a"}, &rochefort.Envelope{
+		Timestamp:   timestamp,
+		ContentType: "application/json",
+		Headers:     map[string]string{"id": "1"},
+		Data:        []byte(`{"a":1}`),
+	})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	e, err := r.GetEnvelope("write", offset)
+	if err != nil || string(e.Data) != `{"a":1}` || e.ContentType != "application/json" || !e.Timestamp.Equal(timestamp) || e.Headers["id"] != "1" || e.Headers["app"] != "test" {
+		t.Logf("unexpected envelope: %+v, %v", e, err)
+		t.FailNow()
+	}
+
+	// the record is wrapped once, so the plain read returns the data
+	data, err := r.GetOne("write", offset)
+	if err != nil || string(data) != `{"a":1}` {
+		t.Logf("unexpected read: %q, %v", data, err)
+		t.FailNow()
+	}
+
+	offset, err = r.AppendEnvelope("write", nil, &rochefort.Envelope{Data: []byte("abc")})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	e, err = r.GetEnvelope("write", offset)
+	if err != nil || string(e.Data) != "abc" || e.ContentType != "text/plain" || e.Headers["id"] != "0" || time.Since(e.Timestamp) > time.Minute {
+		t.Logf("expected the codec defaults, got: %+v, %v", e, err)
+		t.FailNow()
+	}
+
+	// without EnvelopeCodec the envelope is still readable
+	plain := rochefort.NewClient(server.URL, nil)
+	offset, err = plain.AppendEnvelope("write", nil, &rochefort.Envelope{ContentType: "text/plain", Data: []byte("xyz")})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	e, err = plain.GetEnvelope("write", offset)
+	if err != nil || string(e.Data) != "xyz" || e.ContentType != "text/plain" {
+		t.Logf("unexpected envelope: %+v, %v", e, err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,89 @@
+package rochefort
+
+import (
+	"errors"
+	"fmt"
+)
+
+// sentinel errors, use them with errors.Is on the errors returned by the Client
+var (
+	// the namespace or the offset does not exist (status code 404)
+	ErrNotFound = errors.New("not found")
+	// the server rejected the request (status code 4xx)
+	ErrBadRequest = errors.New("bad request")
+	// the server failed to process the request (status code 5xx)
+	ErrServer = errors.New("server error")
+	// the server rejected Modify, e.g. because it writes past the allocated size of the record
+	ErrOutOfBounds = errors.New("modify out of bounds")
+)
+
+// StatusError is returned when the server responds with status code other than 200
+type StatusError struct {
+	// the http status code
+	Code int
+	// the endpoint that returned the error, e.g. "set", "get", "scan", "query"
+	Endpoint string
+	// the response body, usually the error message of the server
+	Body []byte
+}
+
+func (this *StatusError) Error() string {
+	return fmt.Sprintf("%s: expected status code 200, but got: %d, body: %s", this.Endpoint, this.Code, string(this.Body))
+}
+
+// Is makes errors.Is(err, ErrNotFound) and the other sentinel errors work on *StatusError
+func (this *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return this.Code == 404
+	case ErrBadRequest:
+		return this.Code >= 400 && this.Code < 500
+	case ErrServer:
+		return this.Code >= 500
+	}
+	return false
+}
+
+// ModifyError is returned by Modify when the server rejects the modification with status code 4xx (other than 404, 408 and 429), it matches ErrOutOfBounds
+// and through the wrapped *StatusError also ErrBadRequest
+type ModifyError struct {
+	Offset   uint64
+	Position uint32
+	Err      *StatusError
+}
+
+func (this *ModifyError) Error() string {
+	return fmt.Sprintf("modify at offset %d, position %d rejected, error: %s", this.Offset, this.Position, this.Err.Error())
+}
+
+func (this *ModifyError) Is(target error) bool {
+	return target == ErrOutOfBounds
+}
+
+func (this *ModifyError) Unwrap() error {
+	return this.Err
+}
+
+// FrameError is returned when the scan/query stream ends in the middle of a record header or value
+type FrameError struct {
+	// true if the stream ended inside the 12 byte header, in which case Offset is unknown
+	Header bool
+	// the rochefortOffset of the truncated record
+	Offset uint64
+	// number of bytes expected and actually read
+	Expected int
+	Got      int
+	// the underlying read error, usually io.ErrUnexpectedEOF
+	Err error
+}
+
+func (this *FrameError) Error() string {
+	if this.Header {
+		return fmt.Sprintf("truncated frame header, expected %d bytes, but got %d, error: %s", this.Expected, this.Got, this.Err.Error())
+	}
+	return fmt.Sprintf("truncated frame at offset %d, expected %d bytes, but got %d, error: %s", this.Offset, this.Expected, this.Got, this.Err.Error())
+}
+
+func (this *FrameError) Unwrap() error {
+	return this.Err
+}
@@ -0,0 +1,69 @@
+package rochefort_test
+
+import (
+	"errors"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestStatusErrors(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	_, err := r.Get(&rochefort.GetInput{
+		GetPayload: []*rochefort.Get{{Namespace: "errors", Offset: 123}},
+	})
+	var statusErr *rochefort.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != 404 || statusErr.Endpoint != "get" {
+		t.Logf("expected StatusError from get, got: %v", err)
+		t.FailNow()
+	}
+	if !errors.Is(err, rochefort.ErrNotFound) || !errors.Is(err, rochefort.ErrBadRequest) || errors.Is(err, rochefort.ErrServer) {
+		t.Logf("unexpected classification of: %v", err)
+		t.FailNow()
+	}
+
+	out, err := r.Set(&rochefort.AppendInput{
+		AppendPayload: []*rochefort.Append{{Namespace: "errors", Data: []byte("abc")}},
+	})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	_, err = r.Set(&rochefort.AppendInput{
+		ModifyPayload: []*rochefort.Modify{{Namespace: "errors", Offset: out.Offset[0], Pos: 2, Data: []byte("xyz")}},
+	})
+	if !errors.As(err, &statusErr) || statusErr.Endpoint != "set" || errors.Is(err, rochefort.ErrNotFound) {
+		t.Logf("expected rejected modify, got: %v", err)
+		t.FailNow()
+	}
+
+	_, err = r.Modify("errors", out.Offset[0], 2, []byte("xyz"))
+	if !errors.Is(err, rochefort.ErrOutOfBounds) || !errors.Is(err, rochefort.ErrBadRequest) || !errors.As(err, &statusErr) || statusErr.Code != 400 {
+		t.Logf("expected ErrOutOfBounds, got: %v", err)
+		t.FailNow()
+	}
+
+	server.FailNext("set", 1, 503)
+	_, err = r.Modify("errors", out.Offset[0], 0, []byte("x"))
+	if !errors.Is(err, rochefort.ErrServer) || errors.Is(err, rochefort.ErrOutOfBounds) {
+		t.Logf("expected ErrServer, got: %v", err)
+		t.FailNow()
+	}
+
+	_, err = r.Modify("errors", out.Offset[0]+1000, 0, []byte("x"))
+	if !errors.Is(err, rochefort.ErrNotFound) || errors.Is(err, rochefort.ErrOutOfBounds) {
+		t.Logf("expected ErrNotFound, got: %v", err)
+		t.FailNow()
+	}
+
+	err = r.Search("errors", map[string]interface{}{"unknown": "a"}, func(offset uint64, data []byte) {})
+	if !errors.Is(err, rochefort.ErrBadRequest) || errors.Is(err, rochefort.ErrServer) {
+		t.Logf("expected ErrBadRequest, got: %v", err)
+		t.FailNow()
+	}
+}
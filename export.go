@@ -0,0 +1,241 @@
+package rochefort
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DumpFormat is the format of Export and Import
+type DumpFormat int
+
+const (
+	// newline delimited json, one {"offset": 123, "data": "base64", "tags": ["a"]} per record
+	DumpJSONLines DumpFormat = iota
+	// the scan stream encoding, every record is 12 byte header (4 bytes little endian length, 8 bytes little endian offset) followed by the data, it can not carry tags
+	DumpRaw
+)
+
+// ExportOptions controls Export
+type ExportOptions struct {
+	Format DumpFormat
+	// include the tags of the records (only with DumpJSONLines), they are collected by searching for every tag in the namespace stats before the export, so it costs one Search per tag and memory per tagged record
+	Tags bool
+	// decode the records with the payload codecs of the client, by default the dump has the stored bytes, so e.g. encrypted records stay encrypted in the dump
+	Decode bool
+}
+
+// ImportOptions controls Import
+type ImportOptions struct {
+	Format DumpFormat
+	// number of records appended in one request, default 100
+	BatchSize int
+	// if set, "oldOffset newOffset" line is written for every imported record
+	Mapping io.Writer
+	// encode the records with the payload codecs of the client, by default the records are appended as they are in the dump (see ExportOptions.Decode)
+	Encode bool
+}
+
+type dumpRecord struct {
+	Offset uint64   `json:"offset"`
+	Data   []byte   `json:"data"`
+	Tags   []string `json:"tags,omitempty"`
+}
+
+// collectTags returns the tags of every tagged record in the namespace
+func collectTags(ctx context.Context, client Interface, namespace string) (map[uint64][]string, error) {
+	stats, err := client.StatsContext(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[uint64][]string{}
+	// only the offsets are needed, so the data is not decoded
+	raw := rawOf(client)
+	for tag := range stats.Tags {
+		err := raw.SearchFuncContext(ctx, namespace, Tag(tag), func(offset uint64, data []byte) error {
+			tags[offset] = append(tags[offset], tag)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// Export writes every record of the namespace to w, returns the number of exported records
+// the records are written as stored, unless Decode is set
+func (this *Client) Export(ctx context.Context, namespace string, w io.Writer, options ExportOptions) (int, error) {
+	if options.Format != DumpJSONLines && options.Format != DumpRaw {
+		return 0, fmt.Errorf("unknown dump format %d", options.Format)
+	}
+
+	var tags map[uint64][]string
+	if options.Tags {
+		if options.Format != DumpJSONLines {
+			return 0, errors.New("tags can be exported only in json lines format")
+		}
+		var err error
+		tags, err = collectTags(ctx, this, namespace)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	client := this.raw()
+	if options.Decode {
+		client = this
+	}
+
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	n := 0
+	err := client.ScanFuncContext(ctx, namespace, func(offset uint64, data []byte) error {
+		n++
+		if options.Format == DumpRaw {
+			return writeFrame(buffered, offset, data)
+		}
+		return encoder.Encode(&dumpRecord{Offset: offset, Data: data, Tags: tags[offset]})
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, buffered.Flush()
+}
+
+// appendBatcher appends records in batches and reports the new offset of every record
+type appendBatcher struct {
+	client    Interface
+	namespace string
+	size      int
+	sources   []uint64
+	input     *AppendInput
+	stored    func(source uint64, offset uint64) error
+	// called after every stored batch, can be nil
+	flushed func() error
+}
+
+func newAppendBatcher(client Interface, namespace string, size int, stored func(source uint64, offset uint64) error) *appendBatcher {
+	if size <= 0 {
+		size = 100
+	}
+	return &appendBatcher{
+		client:    client,
+		namespace: namespace,
+		size:      size,
+		input:     &AppendInput{},
+		stored:    stored,
+	}
+}
+
+func (this *appendBatcher) add(ctx context.Context, source uint64, tags []string, data []byte) error {
+	this.sources = append(this.sources, source)
+	this.input.AppendPayload = append(this.input.AppendPayload, &Append{
+		Namespace: this.namespace,
+		Tags:      tags,
+		Data:      data,
+	})
+	if len(this.sources) >= this.size {
+		return this.flush(ctx)
+	}
+	return nil
+}
+
+func (this *appendBatcher) flush(ctx context.Context) error {
+	if len(this.sources) == 0 {
+		return nil
+	}
+
+	out, err := this.client.SetContext(ctx, this.input)
+	if err != nil {
+		return err
+	}
+	if len(out.Offset) != len(this.sources) {
+		return fmt.Errorf("expected %d offsets, but got: %d", len(this.sources), len(out.Offset))
+	}
+	for i, source := range this.sources {
+		if err := this.stored(source, out.Offset[i]); err != nil {
+			return err
+		}
+	}
+
+	this.sources = this.sources[:0]
+	this.input = &AppendInput{}
+	if this.flushed != nil {
+		return this.flushed()
+	}
+	return nil
+}
+
+// Import appends the records from r (written by Export) to the namespace, returns the number of imported records
+// the records get new offsets, use Mapping to translate the old ones, they are appended as they are in the dump, unless Encode is set
+func (this *Client) Import(ctx context.Context, r io.Reader, namespace string, options ImportOptions) (int, error) {
+	client := this.raw()
+	if options.Encode {
+		client = this
+	}
+
+	var mapping *bufio.Writer
+	if options.Mapping != nil {
+		mapping = bufio.NewWriter(options.Mapping)
+	}
+
+	n := 0
+	batcher := newAppendBatcher(client, namespace, options.BatchSize, func(source uint64, offset uint64) error {
+		n++
+		if mapping != nil {
+			_, err := fmt.Fprintf(mapping, "%d %d\n", source, offset)
+			return err
+		}
+		return nil
+	})
+
+	if mapping != nil {
+		// the mapping of every stored batch is written right away, so it is complete up to the failure
+		batcher.flushed = mapping.Flush
+	}
+
+	var err error
+	switch options.Format {
+	case DumpJSONLines:
+		decoder := json.NewDecoder(r)
+		for {
+			record := &dumpRecord{}
+			err = decoder.Decode(record)
+			if err != nil {
+				break
+			}
+			if err = batcher.add(ctx, record.Offset, record.Tags, record.Data); err != nil {
+				break
+			}
+		}
+	case DumpRaw:
+		frames := newFrameReader(bufio.NewReader(r))
+		for {
+			var offset uint64
+			var data []byte
+			offset, data, err = frames.next()
+			if err != nil {
+				break
+			}
+			if err = batcher.add(ctx, offset, nil, data); err != nil {
+				break
+			}
+		}
+	default:
+		return 0, fmt.Errorf("unknown dump format %d", options.Format)
+	}
+	if err == io.EOF {
+		err = batcher.flush(ctx)
+	}
+	if mapping != nil {
+		if flushErr := mapping.Flush(); err == nil {
+			err = flushErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,198 @@
+package rochefort_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestExportImport(t *testing.T) {
+	source := rochefortest.NewServer()
+	defer source.Close()
+	destination := rochefortest.NewServer()
+	defer destination.Close()
+
+	src := rochefort.NewClient(source.URL, nil)
+	dst := rochefort.NewClient(destination.URL, nil)
+	ctx := context.Background()
+
+	// make the offsets differ between the servers
+	_, err := dst.Append("imported", nil, 0, []byte("existing"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	values := map[uint64]string{}
+	for i := 0; i < 10; i++ {
+		tags := []string{}
+		if i%2 == 0 {
+			tags = append(tags, "even")
+		}
+		if i%3 == 0 {
+			tags = append(tags, "three")
+		}
+		v := fmt.Sprintf("value-%d", i)
+		off, err := src.Append("exported", tags, 0, []byte(v))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		values[off] = v
+	}
+
+	for _, options := range []rochefort.ExportOptions{{Format: rochefort.DumpJSONLines, Tags: true}, {Format: rochefort.DumpRaw}} {
+		_, err := dst.Delete(&rochefort.NamespaceInput{Namespace: "imported"})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		var dump bytes.Buffer
+		n, err := src.Export(ctx, "exported", &dump, options)
+		if err != nil || n != 10 {
+			t.Logf("unexpected export: %d, %v", n, err)
+			t.FailNow()
+		}
+
+		var mapping bytes.Buffer
+		n, err = dst.Import(ctx, &dump, "imported", rochefort.ImportOptions{Format: options.Format, BatchSize: 3, Mapping: &mapping})
+		if err != nil || n != 10 {
+			t.Logf("unexpected import: %d, %v", n, err)
+			t.FailNow()
+		}
+
+		lines := strings.Split(strings.TrimSpace(mapping.String()), "\n")
+		if len(lines) != 10 {
+			t.Logf("unexpected mapping: %q", mapping.String())
+			t.FailNow()
+		}
+		for _, line := range lines {
+			var old, offset uint64
+			if _, err := fmt.Sscanf(line, "%d %d", &old, &offset); err != nil {
+				t.Log(err)
+				t.FailNow()
+			}
+			data, err := dst.GetOne("imported", offset)
+			if err != nil || string(data) != values[old] {
+				t.Logf("unexpected imported record %d -> %d: %s, %v", old, offset, string(data), err)
+				t.FailNow()
+			}
+		}
+
+		stats, err := dst.Stats("imported")
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if options.Tags && (stats.Tags["even"] != 5 || stats.Tags["three"] != 4) {
+			t.Logf("unexpected imported tags: %v", stats.Tags)
+			t.FailNow()
+		}
+	}
+
+	_, err = src.Export(ctx, "exported", &bytes.Buffer{}, rochefort.ExportOptions{Format: rochefort.DumpRaw, Tags: true})
+	if err == nil {
+		t.Log("expected error for tags in raw format")
+		t.FailNow()
+	}
+
+	// the format is checked before the scan, so it fails even for empty namespace
+	_, err = src.Export(ctx, "empty", &bytes.Buffer{}, rochefort.ExportOptions{Format: 99})
+	if err == nil || source.Requests("scan") != 2 {
+		t.Logf("expected error for unknown format without scan, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestExportImportEncrypted(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	ctx := context.Background()
+
+	keys := &rochefort.StaticKeys{
+		Current: "k1",
+		Keys: map[string][]byte{
+			"k1": bytes.Repeat([]byte{1}, 32),
+		},
+	}
+	r := rochefort.New(server.URL, rochefort.WithEncryption(keys))
+	_, err := r.Append("secret", nil, 0, []byte("john.doe@example.com"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// the dump keeps the stored (encrypted) bytes
+	var dump bytes.Buffer
+	_, err = r.Export(ctx, "secret", &dump, rochefort.ExportOptions{Format: rochefort.DumpRaw})
+	if err != nil || bytes.Contains(dump.Bytes(), []byte("john.doe")) {
+		t.Logf("expected encrypted dump, got: %q, %v", dump.String(), err)
+		t.FailNow()
+	}
+
+	// and the imported records are not encrypted again
+	offsets := &bytes.Buffer{}
+	_, err = r.Import(ctx, &dump, "restored", rochefort.ImportOptions{Format: rochefort.DumpRaw, Mapping: offsets})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	var old, offset uint64
+	fmt.Sscanf(offsets.String(), "%d %d", &old, &offset)
+	data, err := r.GetOne("restored", offset)
+	if err != nil || string(data) != "john.doe@example.com" {
+		t.Logf("unexpected restored record: %q, %v", data, err)
+		t.FailNow()
+	}
+
+	// decoding is explicit
+	dump.Reset()
+	_, err = r.Export(ctx, "secret", &dump, rochefort.ExportOptions{Format: rochefort.DumpRaw, Decode: true})
+	if err != nil || !bytes.Contains(dump.Bytes(), []byte("john.doe")) {
+		t.Logf("expected decoded dump, got: %q, %v", dump.String(), err)
+		t.FailNow()
+	}
+}
+
+func TestImportPartialMapping(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+	ctx := context.Background()
+
+	for _, garbage := range []string{"not json\n", "{\"offset\": 3, \"da"} {
+		dump := "{\"offset\": 0, \"data\": \"YQ==\"}\n{\"offset\": 1, \"data\": \"Yg==\"}\n{\"offset\": 2, \"data\": \"Yw==\"}\n" + garbage
+
+		var mapping bytes.Buffer
+		n, err := r.Import(ctx, strings.NewReader(dump), "partial", rochefort.ImportOptions{Format: rochefort.DumpJSONLines, BatchSize: 2, Mapping: &mapping})
+		if err == nil || n != 2 {
+			t.Logf("expected error after the first batch, got: %d, %v", n, err)
+			t.FailNow()
+		}
+
+		// the stored batch is in the mapping, the pending records are not
+		lines := strings.Split(strings.TrimSpace(mapping.String()), "\n")
+		if len(lines) != n {
+			t.Logf("unexpected partial mapping: %q", mapping.String())
+			t.FailNow()
+		}
+		for i, line := range lines {
+			var old, offset uint64
+			if _, err := fmt.Sscanf(line, "%d %d", &old, &offset); err != nil || old != uint64(i) {
+				t.Logf("unexpected mapping line %q: %v", line, err)
+				t.FailNow()
+			}
+			data, err := r.GetOne("partial", offset)
+			if err != nil || string(data) != string(rune('a'+i)) {
+				t.Logf("unexpected imported record %d -> %d: %s, %v", old, offset, string(data), err)
+				t.FailNow()
+			}
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package rochefort
+
+import (
+	"context"
+	"time"
+)
+
+// FollowConfig controls how Follower polls the namespace
+type FollowConfig struct {
+	// how often to poll for new records, default 1s
+	Interval time.Duration
+	// by default every poll checks the namespace offset with Stats and scans only if there are records past the position,
+	// with DisableStats every poll scans (for servers without the stat endpoint)
+	DisableStats bool
+	// skip the existing records and follow only the ones appended after the first poll
+	StartAtEnd bool
+}
+
+// Follower streams the newly appended records of a namespace, like tail -f
+// the server can not scan from an offset, so when the namespace grows it is scanned from the beginning and only the records past the last seen rochefortOffset are emitted,
+// each poll with new records costs a read of the whole namespace, the polls without new records cost one Stats request
+type Follower struct {
+	client    Interface
+	namespace string
+	config    FollowConfig
+	next      uint64
+	started   bool
+	// the namespace offset before the last scan that read the namespace to the end
+	scanned  uint64
+	complete bool
+}
+
+// NewFollower creates follower starting from the beginning of the namespace (or its end with StartAtEnd), use Seek to continue from a known position
+func NewFollower(client Interface, namespace string, config FollowConfig) *Follower {
+	if config.Interval <= 0 {
+		config.Interval = time.Second
+	}
+	return &Follower{
+		client:    client,
+		namespace: namespace,
+		config:    config,
+	}
+}
+
+// Position returns the rochefortOffset from which the next records are emitted, save it to continue with Seek later
+func (this *Follower) Position() uint64 {
+	return this.next
+}
+
+// Seek makes the follower emit only the records at or after rochefortOffset
+func (this *Follower) Seek(rochefortOffset uint64) {
+	this.next = rochefortOffset
+	this.started = true
+	this.complete = false
+}
+
+// Follow calls the callback for every new record until ctx is done, at which point it returns ctx.Err()
+// if the callback returns ErrStop Follow returns nil, any other error (of the callback or the scan) is returned as is and the position is kept, so Follow can be called again
+func (this *Follower) Follow(ctx context.Context, callback func(rochefortOffset uint64, value []byte) error) error {
+	for {
+		grew := true
+		var size uint64
+		if !this.config.DisableStats || (this.config.StartAtEnd && !this.started) {
+			stats, err := this.client.StatsContext(ctx, this.namespace)
+			if err != nil {
+				return err
+			}
+			if this.config.StartAtEnd && !this.started {
+				this.next = stats.Offset
+			}
+			if !this.config.DisableStats {
+				grew = !this.complete || stats.Offset != this.scanned
+			}
+			size = stats.Offset
+		}
+		this.started = true
+
+		if grew {
+			this.complete = false
+			stopped := false
+			err := this.client.ScanFuncContext(ctx, this.namespace, func(offset uint64, data []byte) error {
+				if offset < this.next {
+					return nil
+				}
+				err := callback(offset, data)
+				if err != nil && err != ErrStop {
+					return err
+				}
+				this.next = offset + 1
+				stopped = err == ErrStop
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if stopped {
+				return nil
+			}
+			this.scanned = size
+			this.complete = true
+		}
+
+		timer := time.NewTimer(this.config.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Follow streams the records appended to the namespace until ctx is done, see Follower
+func (this *Client) Follow(ctx context.Context, namespace string, config FollowConfig, callback func(rochefortOffset uint64, value []byte) error) error {
+	return NewFollower(this, namespace, config).Follow(ctx, callback)
+}
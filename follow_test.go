@@ -0,0 +1,153 @@
+package rochefort_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestFollow(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	for _, config := range []rochefort.FollowConfig{
+		{Interval: time.Millisecond},
+		{Interval: time.Millisecond, DisableStats: true},
+		{Interval: time.Millisecond, StartAtEnd: true},
+		{Interval: time.Millisecond, StartAtEnd: true, DisableStats: true},
+	} {
+		ns := "follow"
+		_, err := r.Delete(&rochefort.NamespaceInput{Namespace: ns})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		_, err = r.Append(ns, nil, 0, []byte("old"))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		// the follower client signals when the first Stats returned, at that point a StartAtEnd follower has its end position
+		statted := make(chan struct{})
+		var once sync.Once
+		fr := rochefort.New(server.URL, rochefort.WithInterceptor(func(ctx context.Context, call rochefort.Call, next rochefort.Handler) error {
+			err := next(ctx, call)
+			if _, ok := call.(*rochefort.StatsCall); ok {
+				once.Do(func() { close(statted) })
+			}
+			return err
+		}))
+
+		f := rochefort.NewFollower(fr, ns, config)
+		ctx, cancel := context.WithCancel(context.Background())
+		seen := make(chan string, 10)
+		done := make(chan error)
+		go func() {
+			done <- f.Follow(ctx, func(offset uint64, data []byte) error {
+				seen <- string(data)
+				return nil
+			})
+		}()
+
+		expected := []string{"old", "a", "b"}
+		if config.StartAtEnd {
+			expected = expected[1:]
+			<-statted
+		}
+
+		for _, v := range []string{"a", "b"} {
+			_, err = r.Append(ns, nil, 0, []byte(v))
+			if err != nil {
+				t.Log(err)
+				t.FailNow()
+			}
+		}
+
+		for _, v := range expected {
+			select {
+			case got := <-seen:
+				if got != v {
+					t.Logf("unexpected record: %s, expected: %s", got, v)
+					t.FailNow()
+				}
+			case <-time.After(time.Second):
+				t.Logf("timeout waiting for %s", v)
+				t.FailNow()
+			}
+		}
+
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Logf("expected context.Canceled, got: %v", err)
+			t.FailNow()
+		}
+		if len(seen) != 0 {
+			t.Logf("unexpected extra records: %d", len(seen))
+			t.FailNow()
+		}
+	}
+}
+
+func TestFollowIdle(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	_, err := r.Append("idle", nil, 0, []byte("a"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = r.Follow(ctx, "idle", rochefort.FollowConfig{Interval: time.Millisecond}, func(offset uint64, data []byte) error {
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// the polls without new records do not scan
+	if server.Requests("scan") != 1 || server.Requests("stat") < 2 {
+		t.Logf("unexpected requests, scan: %d, stat: %d", server.Requests("scan"), server.Requests("stat"))
+		t.FailNow()
+	}
+}
+
+func TestFollowStop(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	for _, v := range []string{"a", "b", "c"} {
+		_, err := r.Append("follow", nil, 0, []byte(v))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	f := rochefort.NewFollower(r, "follow", rochefort.FollowConfig{Interval: time.Millisecond})
+	for _, v := range []string{"a", "b", "c"} {
+		err := f.Follow(context.Background(), func(offset uint64, data []byte) error {
+			if string(data) != v {
+				t.Logf("unexpected record: %s, expected: %s", string(data), v)
+				t.FailNow()
+			}
+			return rochefort.ErrStop
+		})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+}
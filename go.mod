@@ -0,0 +1,5 @@
+module github.com/jackdoe/go-rochefort-client
+
+go 1.19
+
+require github.com/gogo/protobuf v1.3.2
@@ -0,0 +1,194 @@
+package rochefort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// Call describes one Client operation as seen by the interceptors, it is one of
+// *SetCall, *GetCall, *ScanCall, *SearchCall, *CompactCall, *DeleteCall and *StatsCall
+// the interceptors can change the request fields before calling next, and the Output after it
+type Call interface {
+	Operation() Operation
+	execute(ctx context.Context, client *Client) error
+}
+
+// Handler executes the call, the last handler of the chain sends it to the server
+type Handler func(ctx context.Context, call Call) error
+
+// Interceptor wraps every Client operation, it can call next with the same call (possibly with modified ctx or call fields), return without calling it to short-circuit the operation,
+// or inspect the returned error and the call output after next returns, an interceptor that short-circuits without error must set the Output of the call, otherwise the operation fails with ErrNoOutput
+// the results are read from the call passed to the interceptor, so passing another call to next loses them
+// example, reject the namespaces of other tenants:
+//
+//	func tenant(ctx context.Context, call rochefort.Call, next rochefort.Handler) error {
+//		if c, ok := call.(*rochefort.StatsCall); ok && !strings.HasPrefix(c.Namespace, "tenant-a/") {
+//			return errForbidden
+//		}
+//		return next(ctx, call)
+//	}
+type Interceptor func(ctx context.Context, call Call, next Handler) error
+
+// ErrNoOutput is returned when the interceptors finish Set, Get, Compact, Delete or Stats without error, but also without setting the Output of the call
+var ErrNoOutput = errors.New("interceptor did not set the call output")
+
+// WithInterceptor adds interceptor to the client, the first added interceptor is the outermost
+// the interceptors run before the retries, metrics and tracing of the operation, so a short-circuited call is not observed
+func WithInterceptor(interceptor Interceptor) Option {
+	return func(o *options) {
+		o.interceptors = append(o.interceptors, interceptor)
+	}
+}
+
+// intercept passes the call through the interceptors and executes it
+func (this *Client) intercept(ctx context.Context, call Call) error {
+	return this.handler(0)(ctx, call)
+}
+
+func (this *Client) handler(i int) Handler {
+	if i == len(this.interceptors) {
+		return func(ctx context.Context, call Call) error {
+			return call.execute(ctx, this)
+		}
+	}
+	return func(ctx context.Context, call Call) error {
+		return this.interceptors[i](ctx, call, this.handler(i+1))
+	}
+}
+
+// SetCall is the call of Set, Input has the data before the payload codecs
+type SetCall struct {
+	Input  *AppendInput
+	Output *AppendOutput
+}
+
+func (this *SetCall) Operation() Operation {
+	return OpSet
+}
+
+func (this *SetCall) execute(ctx context.Context, client *Client) error {
+	ctx, o := client.observe(ctx, OpSet, inputNamespace(this.Input), len(this.Input.AppendPayload)+len(this.Input.ModifyPayload))
+	var err error
+	this.Output, err = client.set(ctx, this.Input)
+	o.finish(err)
+	return err
+}
+
+// GetCall is the call of Get, Output has the data after the payload codecs
+type GetCall struct {
+	Input  *GetInput
+	Output [][]byte
+}
+
+func (this *GetCall) Operation() Operation {
+	return OpGet
+}
+
+func (this *GetCall) execute(ctx context.Context, client *Client) error {
+	ctx, o := client.observe(ctx, OpGet, getNamespace(this.Input), len(this.Input.GetPayload))
+	var err error
+	this.Output, err = client.get(ctx, this.Input)
+	o.finish(err)
+	return err
+}
+
+// ScanCall is the call of Scan, interceptors can wrap the Callback to observe or filter the records
+// the Callback is nil for ScanIterator, in which case the records can not be intercepted
+type ScanCall struct {
+	Namespace string
+	Callback  func(rochefortOffset uint64, value []byte) error
+
+	pull     bool
+	iterator *Iterator
+}
+
+func (this *ScanCall) Operation() Operation {
+	return OpScan
+}
+
+func (this *ScanCall) execute(ctx context.Context, client *Client) error {
+	var err error
+	this.iterator, err = client.stream(ctx, OpScan, this.Namespace, func(ctx context.Context) (*http.Response, error) {
+		return client.scan(ctx, this.Namespace)
+	}, this.Callback, this.pull)
+	return err
+}
+
+// SearchCall is the call of Search, interceptors can wrap the Callback to observe or filter the records
+// the Callback is nil for SearchIterator, in which case the records can not be intercepted
+type SearchCall struct {
+	Namespace string
+	// Query or map[string]interface{}
+	Query    interface{}
+	Callback func(rochefortOffset uint64, value []byte) error
+
+	pull     bool
+	iterator *Iterator
+}
+
+func (this *SearchCall) Operation() Operation {
+	return OpSearch
+}
+
+func (this *SearchCall) execute(ctx context.Context, client *Client) error {
+	var err error
+	this.iterator, err = client.stream(ctx, OpSearch, this.Namespace, func(ctx context.Context) (*http.Response, error) {
+		return client.search(ctx, this.Namespace, this.Query)
+	}, this.Callback, this.pull)
+	return err
+}
+
+// CompactCall is the call of Compact
+type CompactCall struct {
+	Input  *NamespaceInput
+	Output *SuccessOutput
+}
+
+func (this *CompactCall) Operation() Operation {
+	return OpCompact
+}
+
+func (this *CompactCall) execute(ctx context.Context, client *Client) error {
+	ctx, o := client.observe(ctx, OpCompact, this.Input.Namespace, 0)
+	var err error
+	this.Output, err = client.namespaceCall(ctx, OpCompact, client.compactUrl, this.Input)
+	o.finish(err)
+	return err
+}
+
+// DeleteCall is the call of Delete
+type DeleteCall struct {
+	Input  *NamespaceInput
+	Output *SuccessOutput
+}
+
+func (this *DeleteCall) Operation() Operation {
+	return OpDelete
+}
+
+func (this *DeleteCall) execute(ctx context.Context, client *Client) error {
+	ctx, o := client.observe(ctx, OpDelete, this.Input.Namespace, 0)
+	var err error
+	this.Output, err = client.namespaceCall(ctx, OpDelete, client.deleteUrl, this.Input)
+	o.finish(err)
+	return err
+}
+
+// StatsCall is the call of Stats
+type StatsCall struct {
+	Namespace string
+	Output    *StatsOutput
+}
+
+func (this *StatsCall) Operation() Operation {
+	return OpStats
+}
+
+func (this *StatsCall) execute(ctx context.Context, client *Client) error {
+	ctx, o := client.observe(ctx, OpStats, this.Namespace, 0)
+	var err error
+	this.Output, err = client.stats(ctx, this.Namespace)
+	o.finish(err)
+	return err
+}
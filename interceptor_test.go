@@ -0,0 +1,211 @@
+package rochefort_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestInterceptor(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	forbidden := errors.New("forbidden")
+	var trail []string
+
+	// prefixes the namespaces of the tenant
+	tenant := func(ctx context.Context, call rochefort.Call, next rochefort.Handler) error {
+		trail = append(trail, "tenant:"+string(call.Operation()))
+		switch c := call.(type) {
+		case *rochefort.SetCall:
+			for _, a := range c.Input.AppendPayload {
+				a.Namespace = "tenant/" + a.Namespace
+			}
+		case *rochefort.ScanCall:
+			c.Namespace = "tenant/" + c.Namespace
+		case *rochefort.StatsCall:
+			if strings.HasPrefix(c.Namespace, "secret") {
+				return forbidden
+			}
+		}
+		return next(ctx, call)
+	}
+
+	// answers the gets from cache, and counts the scanned records
+	cache := map[uint64][]byte{}
+	scanned := 0
+	audit := func(ctx context.Context, call rochefort.Call, next rochefort.Handler) error {
+		trail = append(trail, "audit:"+string(call.Operation()))
+		switch c := call.(type) {
+		case *rochefort.GetCall:
+			if len(c.Input.GetPayload) == 1 {
+				if data, ok := cache[c.Input.GetPayload[0].Offset]; ok {
+					c.Output = [][]byte{data}
+					return nil
+				}
+			}
+		case *rochefort.ScanCall:
+			if c.Callback == nil {
+				return nil
+			}
+			callback := c.Callback
+			c.Callback = func(offset uint64, data []byte) error {
+				scanned++
+				return callback(offset, data)
+			}
+		}
+		err := next(ctx, call)
+		if c, ok := call.(*rochefort.SetCall); ok && err == nil {
+			for i, a := range c.Input.AppendPayload {
+				cache[c.Output.Offset[i]] = a.Data
+			}
+		}
+		return err
+	}
+
+	r := rochefort.New(server.URL, rochefort.WithInterceptor(tenant), rochefort.WithInterceptor(audit))
+
+	offset, err := r.Append("events", nil, 0, []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if len(trail) != 2 || trail[0] != "tenant:set" || trail[1] != "audit:set" {
+		t.Logf("unexpected order: %v", trail)
+		t.FailNow()
+	}
+	namespaces := server.Namespaces()
+	if len(namespaces) != 1 || namespaces[0] != "tenant/events" {
+		t.Logf("expected the tenant namespace, got: %v", namespaces)
+		t.FailNow()
+	}
+
+	data, err := r.GetOne("tenant/events", offset)
+	if err != nil || string(data) != "hello" || server.Requests("get") != 0 {
+		t.Logf("expected cached get, got: %s, %v, requests: %d", string(data), err, server.Requests("get"))
+		t.FailNow()
+	}
+
+	var values []string
+	err = r.Scan("events", func(offset uint64, data []byte) {
+		values = append(values, string(data))
+	})
+	if err != nil || len(values) != 1 || values[0] != "hello" || scanned != 1 {
+		t.Logf("unexpected scan: %v, %d, %v", values, scanned, err)
+		t.FailNow()
+	}
+
+	it, err := r.ScanIterator(context.Background(), "events")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if it.Next() || it.Err() != nil || server.Requests("scan") != 1 {
+		t.Logf("expected short-circuited empty iterator, got: %v, requests: %d", it.Err(), server.Requests("scan"))
+		t.FailNow()
+	}
+
+	_, err = r.Stats("secret")
+	if err != forbidden || server.Requests("stat") != 0 {
+		t.Logf("expected forbidden, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestInterceptorExportTags(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	operations := map[rochefort.Operation]int{}
+	count := func(ctx context.Context, call rochefort.Call, next rochefort.Handler) error {
+		operations[call.Operation()]++
+		return next(ctx, call)
+	}
+	r := rochefort.New(server.URL, rochefort.WithInterceptor(count))
+
+	for _, tags := range [][]string{{"a"}, {"a", "b"}, nil} {
+		if _, err := r.Append("export", tags, 0, []byte("x")); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	_, err := r.Export(context.Background(), "export", &bytes.Buffer{}, rochefort.ExportOptions{Tags: true})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// the tags are collected with one search per tag
+	if operations[rochefort.OpStats] != 1 || operations[rochefort.OpSearch] != 2 || operations[rochefort.OpScan] != 1 || server.Requests("query") != 2 {
+		t.Logf("expected every request to be intercepted, got: %v", operations)
+		t.FailNow()
+	}
+}
+
+func TestInterceptorNoOutput(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	// passes a copy of the call to next, so the output is lost
+	copying := func(ctx context.Context, call rochefort.Call, next rochefort.Handler) error {
+		if c, ok := call.(*rochefort.StatsCall); ok {
+			copied := *c
+			return next(ctx, &copied)
+		}
+		return next(ctx, call)
+	}
+	// short-circuits without setting the output
+	skipping := func(ctx context.Context, call rochefort.Call, next rochefort.Handler) error {
+		if call.Operation() == rochefort.OpStats {
+			return next(ctx, call)
+		}
+		return nil
+	}
+
+	r := rochefort.New(server.URL, rochefort.WithInterceptor(copying))
+	if _, err := r.Stats("no-output"); !errors.Is(err, rochefort.ErrNoOutput) {
+		t.Logf("expected ErrNoOutput for copied call, got: %v", err)
+		t.FailNow()
+	}
+
+	r = rochefort.New(server.URL, rochefort.WithInterceptor(skipping))
+	if _, err := r.Append("no-output", nil, 0, []byte("a")); !errors.Is(err, rochefort.ErrNoOutput) {
+		t.Logf("expected ErrNoOutput for set, got: %v", err)
+		t.FailNow()
+	}
+	if _, err := r.GetOne("no-output", 0); !errors.Is(err, rochefort.ErrNoOutput) {
+		t.Logf("expected ErrNoOutput for get, got: %v", err)
+		t.FailNow()
+	}
+	if _, err := r.Delete(&rochefort.NamespaceInput{Namespace: "no-output"}); !errors.Is(err, rochefort.ErrNoOutput) {
+		t.Logf("expected ErrNoOutput for delete, got: %v", err)
+		t.FailNow()
+	}
+	// empty get has nothing to return
+	if _, err := r.Get(&rochefort.GetInput{}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// the check is against the input after the interceptors, one that drops every offset has nothing to return either
+	dropping := func(ctx context.Context, call rochefort.Call, next rochefort.Handler) error {
+		if c, ok := call.(*rochefort.GetCall); ok {
+			c.Input = &rochefort.GetInput{}
+			return nil
+		}
+		return next(ctx, call)
+	}
+	r = rochefort.New(server.URL, rochefort.WithInterceptor(dropping))
+	if _, err := r.Get(&rochefort.GetInput{GetPayload: []*rochefort.Get{{Namespace: "no-output", Offset: 0}}}); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if server.Requests("set") != 0 || server.Requests("get") != 0 {
+		t.Log("expected no requests")
+		t.FailNow()
+	}
+}
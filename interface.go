@@ -0,0 +1,36 @@
+package rochefort
+
+import "context"
+
+// Interface is the set of Client operations, depend on it instead of *Client to replace the client in tests, e.g. with rochefortest.Mock
+// the helpers of the package (NewBatchAppender, NewProducer, NewFollower, NewConsumer, NewNamespace and Migrate) accept it as well
+type Interface interface {
+	Set(input *AppendInput) (*AppendOutput, error)
+	SetContext(ctx context.Context, input *AppendInput) (*AppendOutput, error)
+	Append(namespace string, tags []string, allocSize uint32, data []byte) (uint64, error)
+	AppendContext(ctx context.Context, namespace string, tags []string, allocSize uint32, data []byte) (uint64, error)
+	Modify(namespace string, offset uint64, position uint32, data []byte) (bool, error)
+	ModifyContext(ctx context.Context, namespace string, offset uint64, position uint32, data []byte) (bool, error)
+	Get(input *GetInput) ([][]byte, error)
+	GetContext(ctx context.Context, input *GetInput) ([][]byte, error)
+	GetOne(namespace string, offset uint64) ([]byte, error)
+	GetOneContext(ctx context.Context, namespace string, offset uint64) ([]byte, error)
+	GetMulti(namespace string, offsets []uint64) ([][]byte, error)
+	GetMultiContext(ctx context.Context, namespace string, offsets []uint64) ([][]byte, error)
+	Scan(namespace string, callback func(rochefortOffset uint64, value []byte)) error
+	ScanContext(ctx context.Context, namespace string, callback func(rochefortOffset uint64, value []byte)) error
+	ScanFunc(namespace string, callback func(rochefortOffset uint64, value []byte) error) error
+	ScanFuncContext(ctx context.Context, namespace string, callback func(rochefortOffset uint64, value []byte) error) error
+	Search(namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte)) error
+	SearchContext(ctx context.Context, namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte)) error
+	SearchFunc(namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte) error) error
+	SearchFuncContext(ctx context.Context, namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte) error) error
+	Compact(input *NamespaceInput) (*SuccessOutput, error)
+	CompactContext(ctx context.Context, input *NamespaceInput) (*SuccessOutput, error)
+	Delete(input *NamespaceInput) (*SuccessOutput, error)
+	DeleteContext(ctx context.Context, input *NamespaceInput) (*SuccessOutput, error)
+	Stats(namespace string) (*StatsOutput, error)
+	StatsContext(ctx context.Context, namespace string) (*StatsOutput, error)
+}
+
+var _ Interface = (*Client)(nil)
@@ -0,0 +1,185 @@
+package rochefort
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+)
+
+// frameReader decodes the scan/query stream, every record is prefixed with 12 byte header, 4 bytes little endian length and 8 bytes little endian offset
+type frameReader struct {
+	r      io.Reader
+	header []byte
+	decode func(offset uint64, data []byte) ([]byte, error)
+}
+
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{r: r, header: make([]byte, 12)}
+}
+
+// frames returns frame reader that applies the payload codecs of the client
+func (this *Client) frames(r io.Reader) *frameReader {
+	frames := newFrameReader(r)
+	if len(this.codecs) > 0 {
+		frames.decode = this.decode
+	}
+	return frames
+}
+
+// next returns the next record, or io.EOF if the stream ended cleanly, *FrameError if it ended in the middle of a record
+func (this *frameReader) next() (uint64, []byte, error) {
+	n, err := io.ReadFull(this.r, this.header)
+	if err == io.ErrUnexpectedEOF {
+		return 0, nil, &FrameError{Header: true, Expected: len(this.header), Got: n, Err: err}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	len := binary.LittleEndian.Uint32(this.header)
+	offset := binary.LittleEndian.Uint64(this.header[4:])
+
+	data := make([]byte, len)
+	n, err = io.ReadFull(this.r, data)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, &FrameError{Offset: offset, Expected: int(len), Got: n, Err: err}
+	}
+	if this.decode != nil {
+		data, err = this.decode(offset, data)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+	return offset, data, nil
+}
+
+// writeFrame encodes the record in the scan/query stream format
+func writeFrame(w io.Writer, offset uint64, data []byte) error {
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint32(header, uint32(len(data)))
+	binary.LittleEndian.PutUint64(header[4:], offset)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// Iterator pulls records from Scan or Search one by one, it must be closed if it is not consumed until Next returns false
+// example:
+//
+//	it, err := r.ScanIterator(ctx, ns)
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		process(it.Offset(), it.Value())
+//	}
+//	return it.Err()
+type Iterator struct {
+	ctx    context.Context
+	body   io.ReadCloser
+	frames *frameReader
+	offset uint64
+	value  []byte
+	err    error
+	closed bool
+
+	observation *observation
+}
+
+func newIterator(ctx context.Context, body io.ReadCloser, frames *frameReader, o *observation) *Iterator {
+	return &Iterator{
+		ctx:         ctx,
+		body:        body,
+		frames:      frames,
+		observation: o,
+	}
+}
+
+// ScanIterator is like ScanContext, but the records are pulled with the returned Iterator instead of pushed to a callback
+func (this *Client) ScanIterator(ctx context.Context, namespace string) (*Iterator, error) {
+	call := &ScanCall{Namespace: namespace, pull: true}
+	if err := this.intercept(ctx, call); err != nil {
+		return nil, err
+	}
+	return call.iterator.orEmpty(ctx), nil
+}
+
+// SearchIterator is like SearchContext, but the records are pulled with the returned Iterator instead of pushed to a callback
+func (this *Client) SearchIterator(ctx context.Context, namespace string, query interface{}) (*Iterator, error) {
+	call := &SearchCall{Namespace: namespace, Query: query, pull: true}
+	if err := this.intercept(ctx, call); err != nil {
+		return nil, err
+	}
+	return call.iterator.orEmpty(ctx), nil
+}
+
+// orEmpty returns empty iterator instead of nil, when an interceptor short-circuited the call
+func (this *Iterator) orEmpty(ctx context.Context) *Iterator {
+	if this != nil {
+		return this
+	}
+	body := ioutil.NopCloser(bytes.NewReader(nil))
+	return newIterator(ctx, body, newFrameReader(body), nil)
+}
+
+// Next advances to the next record, returns false at the end of the stream or on error (check Err), at which point the iterator is closed
+func (this *Iterator) Next() bool {
+	if this.closed {
+		return false
+	}
+
+	if err := this.ctx.Err(); err != nil {
+		this.err = err
+		this.Close()
+		return false
+	}
+
+	offset, data, err := this.frames.next()
+	if err != nil {
+		if err != io.EOF {
+			this.err = err
+		}
+		this.Close()
+		return false
+	}
+
+	this.observation.record()
+	this.offset = offset
+	this.value = data
+	return true
+}
+
+// Offset returns the rochefortOffset of the current record
+func (this *Iterator) Offset() uint64 {
+	return this.offset
+}
+
+// Value returns the value of the current record
+func (this *Iterator) Value() []byte {
+	return this.value
+}
+
+// Err returns the error that stopped the iteration, nil if the stream ended cleanly or the iterator was closed by the caller
+func (this *Iterator) Err() error {
+	return this.err
+}
+
+// Close releases the response body, it is safe to call it multiple times
+func (this *Iterator) Close() error {
+	if this.closed {
+		return nil
+	}
+	this.closed = true
+	this.value = nil
+	err := this.body.Close()
+	this.observation.finish(this.err)
+	return err
+}
@@ -0,0 +1,25 @@
+//go:build go1.23
+
+package rochefort
+
+import (
+	"iter"
+)
+
+// All returns the remaining records as iter.Seq2 of rochefortOffset and value, the iterator is closed when the loop ends or breaks, check Err afterwards
+// example:
+//
+//	for offset, value := range it.All() {
+//		process(offset, value)
+//	}
+//	return it.Err()
+func (this *Iterator) All() iter.Seq2[uint64, []byte] {
+	return func(yield func(uint64, []byte) bool) {
+		defer this.Close()
+		for this.Next() {
+			if !yield(this.Offset(), this.Value()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build go1.23
+
+package rochefort
+
+import (
+	"context"
+	"testing"
+)
+
+func TestIteratorAll(t *testing.T) {
+	server := framesServer(10)
+	defer server.Close()
+
+	r := NewClient(server.URL, nil)
+	it, err := r.ScanIterator(context.Background(), "ns")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	n := 0
+	for offset := range it.All() {
+		if offset == 3 {
+			break
+		}
+		n++
+	}
+	if n != 3 {
+		t.Logf("expected 3 records before break, got: %d", n)
+		t.FailNow()
+	}
+	if !it.closed {
+		t.Log("expected iterator to be closed after break")
+		t.FailNow()
+	}
+}
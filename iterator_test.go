@@ -0,0 +1,69 @@
+package rochefort
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScanIterator(t *testing.T) {
+	server := framesServer(10)
+	defer server.Close()
+
+	r := NewClient(server.URL, nil)
+	it, err := r.ScanIterator(context.Background(), "ns")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer it.Close()
+
+	n := uint64(0)
+	for it.Next() {
+		if it.Offset() != n || string(it.Value()) != "abc" {
+			t.Logf("unexpected record at %d: %s", it.Offset(), string(it.Value()))
+			t.FailNow()
+		}
+		n++
+	}
+	if it.Err() != nil {
+		t.Log(it.Err())
+		t.FailNow()
+	}
+	if n != 10 {
+		t.Logf("expected 10 records, got: %d", n)
+		t.FailNow()
+	}
+	if it.Next() {
+		t.Log("Next after the end returned true")
+		t.FailNow()
+	}
+}
+
+func TestIteratorTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 'a'})
+	}))
+	defer server.Close()
+
+	r := NewClient(server.URL, nil)
+	it, err := r.SearchIterator(context.Background(), "ns", Tag("a"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	defer it.Close()
+
+	if it.Next() {
+		t.Log("expected no records")
+		t.FailNow()
+	}
+	var frameErr *FrameError
+	if !errors.As(it.Err(), &frameErr) || frameErr.Expected != 10 || frameErr.Got != 1 || !errors.Is(it.Err(), io.ErrUnexpectedEOF) {
+		t.Logf("expected FrameError for truncated frame, got: %v", it.Err())
+		t.FailNow()
+	}
+}
@@ -0,0 +1,226 @@
+package rochefort
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// MigrateCheckpoint is the progress of Migrate, saved after every stored batch
+type MigrateCheckpoint struct {
+	// the records of the source before this offset are already copied
+	Next uint64 `json:"next"`
+	// the destination offset before the first copied record, the records from here on are verified
+	DestinationStart uint64 `json:"destinationStart"`
+	// number of copied records
+	Copied int `json:"copied"`
+}
+
+// CheckpointStore keeps the migration checkpoint between runs, Load returns nil checkpoint if there is none
+type CheckpointStore interface {
+	Load() (*MigrateCheckpoint, error)
+	Save(checkpoint *MigrateCheckpoint) error
+}
+
+// FileCheckpoint stores the checkpoint as json file, replaced atomically on every save
+type FileCheckpoint struct {
+	Path string
+}
+
+func (this *FileCheckpoint) Load() (*MigrateCheckpoint, error) {
+	data, err := ioutil.ReadFile(this.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &MigrateCheckpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (this *FileCheckpoint) Save(checkpoint *MigrateCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(this.Path), filepath.Base(this.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), this.Path)
+}
+
+// MigrateOptions controls Migrate
+type MigrateOptions struct {
+	// the source namespace
+	Namespace string
+	// the destination namespace, default Namespace
+	DestinationNamespace string
+	// number of records appended in one request, default 100
+	BatchSize int
+	// copy the tags as well, see ExportOptions.Tags
+	Tags bool
+	// if set, the migration continues from the saved checkpoint
+	Checkpoint CheckpointStore
+	// if set, "sourceOffset destinationOffset" line is written for every copied record, open it in append mode when resuming
+	Translation io.Writer
+	// compare the record count and checksum of the source with the copied records in the destination
+	Verify bool
+}
+
+// MigrateResult is the outcome of Migrate
+type MigrateResult struct {
+	// records copied by this run and in total (including the previous runs)
+	Copied      int
+	TotalCopied int
+	// set if Verify was requested
+	SourceCount         int
+	SourceChecksum      uint32
+	DestinationCount    int
+	DestinationChecksum uint32
+}
+
+// VerifyError is returned by Migrate when the copied records do not match the source
+type VerifyError struct {
+	Result *MigrateResult
+}
+
+func (this *VerifyError) Error() string {
+	return fmt.Sprintf("migration verification failed, source: %d records, checksum %08x, destination: %d records, checksum %08x", this.Result.SourceCount, this.Result.SourceChecksum, this.Result.DestinationCount, this.Result.DestinationChecksum)
+}
+
+// namespaceChecksum returns the number of records at or after from and CRC32C of their lengths and data, in scan order
+func namespaceChecksum(ctx context.Context, client Interface, namespace string, from uint64) (int, uint32, error) {
+	n := 0
+	crc := uint32(0)
+	length := make([]byte, 4)
+	err := client.ScanFuncContext(ctx, namespace, func(offset uint64, data []byte) error {
+		if offset < from {
+			return nil
+		}
+		n++
+		binary.LittleEndian.PutUint32(length, uint32(len(data)))
+		crc = crc32.Update(crc, castagnoli, length)
+		crc = crc32.Update(crc, castagnoli, data)
+		return nil
+	})
+	return n, crc, err
+}
+
+// Migrate copies the namespace from src to dst in batches, the copied records get new offsets, written to Translation
+// after failure run it again with the same Checkpoint to continue, a batch stored right before the failure can be copied twice (which Verify reports)
+// Verify assumes nothing else appends to the destination namespace during the migration
+func Migrate(ctx context.Context, src Interface, dst Interface, options MigrateOptions) (*MigrateResult, error) {
+	destination := options.DestinationNamespace
+	if destination == "" {
+		destination = options.Namespace
+	}
+
+	var checkpoint *MigrateCheckpoint
+	if options.Checkpoint != nil {
+		var err error
+		checkpoint, err = options.Checkpoint.Load()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if checkpoint == nil {
+		stats, err := dst.StatsContext(ctx, destination)
+		if err != nil {
+			return nil, err
+		}
+		checkpoint = &MigrateCheckpoint{DestinationStart: stats.Offset}
+		if options.Checkpoint != nil {
+			if err := options.Checkpoint.Save(checkpoint); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var tags map[uint64][]string
+	if options.Tags {
+		var err error
+		tags, err = collectTags(ctx, src, options.Namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var translation *bufio.Writer
+	if options.Translation != nil {
+		translation = bufio.NewWriter(options.Translation)
+	}
+
+	result := &MigrateResult{}
+	start := checkpoint.Next
+	batcher := newAppendBatcher(dst, destination, options.BatchSize, func(source uint64, offset uint64) error {
+		result.Copied++
+		checkpoint.Copied++
+		checkpoint.Next = source + 1
+		if translation != nil {
+			_, err := fmt.Fprintf(translation, "%d %d\n", source, offset)
+			return err
+		}
+		return nil
+	})
+	batcher.flushed = func() error {
+		if translation != nil {
+			if err := translation.Flush(); err != nil {
+				return err
+			}
+		}
+		if options.Checkpoint != nil {
+			return options.Checkpoint.Save(checkpoint)
+		}
+		return nil
+	}
+
+	err := src.ScanFuncContext(ctx, options.Namespace, func(offset uint64, data []byte) error {
+		if offset < start {
+			return nil
+		}
+		return batcher.add(ctx, offset, tags[offset], data)
+	})
+	if err == nil {
+		err = batcher.flush(ctx)
+	}
+	result.TotalCopied = checkpoint.Copied
+	if err != nil {
+		return result, err
+	}
+
+	if options.Verify {
+		result.SourceCount, result.SourceChecksum, err = namespaceChecksum(ctx, src, options.Namespace, 0)
+		if err != nil {
+			return result, err
+		}
+		result.DestinationCount, result.DestinationChecksum, err = namespaceChecksum(ctx, dst, destination, checkpoint.DestinationStart)
+		if err != nil {
+			return result, err
+		}
+		if result.SourceCount != result.DestinationCount || result.SourceChecksum != result.DestinationChecksum {
+			return result, &VerifyError{Result: result}
+		}
+	}
+	return result, nil
+}
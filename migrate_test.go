@@ -0,0 +1,168 @@
+package rochefort_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+// failingCheckpoint calls fail before the nth save
+type failingCheckpoint struct {
+	rochefort.CheckpointStore
+	saves int
+	n     int
+	fail  func()
+}
+
+func (this *failingCheckpoint) Save(checkpoint *rochefort.MigrateCheckpoint) error {
+	this.saves++
+	if this.saves == this.n {
+		this.fail()
+	}
+	return this.CheckpointStore.Save(checkpoint)
+}
+
+type memoryCheckpoint struct {
+	checkpoint *rochefort.MigrateCheckpoint
+}
+
+func (this *memoryCheckpoint) Load() (*rochefort.MigrateCheckpoint, error) {
+	return this.checkpoint, nil
+}
+
+func (this *memoryCheckpoint) Save(checkpoint *rochefort.MigrateCheckpoint) error {
+	this.checkpoint = checkpoint
+	return nil
+}
+
+func TestMigrate(t *testing.T) {
+	source := rochefortest.NewServer()
+	defer source.Close()
+	destination := rochefortest.NewServer()
+	defer destination.Close()
+
+	src := rochefort.NewClient(source.URL, nil)
+	dst := rochefort.NewClient(destination.URL, nil)
+	ctx := context.Background()
+
+	_, err := dst.Append("moved", nil, 0, []byte("existing record"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	values := map[uint64]string{}
+	for i := 0; i < 25; i++ {
+		v := fmt.Sprintf("value-%d", i)
+		off, err := src.Append("events", []string{fmt.Sprintf("tag-%d", i%3)}, 0, []byte(v))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		values[off] = v
+	}
+
+	// the initial save and two batches succeed, then the destination goes down
+	checkpoint := &rochefort.FileCheckpoint{Path: filepath.Join(t.TempDir(), "checkpoint.json")}
+	var translation bytes.Buffer
+	options := rochefort.MigrateOptions{
+		Namespace:            "events",
+		DestinationNamespace: "moved",
+		BatchSize:            4,
+		Tags:                 true,
+		Checkpoint: &failingCheckpoint{CheckpointStore: checkpoint, n: 3, fail: func() {
+			destination.FailNext("set", 1, 503)
+		}},
+		Translation: &translation,
+		Verify:      true,
+	}
+	result, err := rochefort.Migrate(ctx, src, dst, options)
+	if !errors.Is(err, rochefort.ErrServer) || result.TotalCopied != 8 {
+		t.Logf("expected failure after two batches, got: %+v, %v", result, err)
+		t.FailNow()
+	}
+
+	options.Checkpoint = checkpoint
+	result, err = rochefort.Migrate(ctx, src, dst, options)
+	if err != nil {
+		t.Logf("unexpected resume: %+v, %v", result, err)
+		t.FailNow()
+	}
+	if result.Copied != 17 || result.TotalCopied != 25 || result.SourceCount != 25 || result.DestinationCount != 25 || result.SourceChecksum != result.DestinationChecksum {
+		t.Logf("unexpected result: %+v", result)
+		t.FailNow()
+	}
+
+	seen := map[uint64]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(translation.String()), "\n") {
+		var old, offset uint64
+		if _, err := fmt.Sscanf(line, "%d %d", &old, &offset); err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		seen[old] = true
+		data, err := dst.GetOne("moved", offset)
+		if err != nil || string(data) != values[old] {
+			t.Logf("unexpected copied record %d -> %d: %s, %v", old, offset, string(data), err)
+			t.FailNow()
+		}
+	}
+	if len(seen) != 25 {
+		t.Logf("expected translation of 25 records, got: %d", len(seen))
+		t.FailNow()
+	}
+
+	stats, err := dst.Stats("moved")
+	if err != nil || stats.Tags["tag-0"] != 9 {
+		t.Logf("unexpected destination tags: %v, %v", stats, err)
+		t.FailNow()
+	}
+}
+
+func TestMigrateVerifyError(t *testing.T) {
+	source := rochefortest.NewServer()
+	defer source.Close()
+	destination := rochefortest.NewServer()
+	defer destination.Close()
+
+	src := rochefort.NewClient(source.URL, nil)
+	dst := rochefort.NewClient(destination.URL, nil)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_, err := src.Append("events", nil, 0, []byte(fmt.Sprintf("value-%d", i)))
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	checkpoint := &memoryCheckpoint{}
+	options := rochefort.MigrateOptions{Namespace: "events", Checkpoint: checkpoint, Verify: true}
+	_, err := rochefort.Migrate(ctx, src, dst, options)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	_, err = dst.Append("events", nil, 0, []byte("extra"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// nothing left to copy, but the destination has one record more
+	result, err := rochefort.Migrate(ctx, src, dst, options)
+	var verifyErr *rochefort.VerifyError
+	if !errors.As(err, &verifyErr) || result.Copied != 0 || result.SourceCount != 5 || result.DestinationCount != 6 {
+		t.Logf("expected VerifyError, got: %+v, %v", result, err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,194 @@
+package rochefort
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	proto "github.com/gogo/protobuf/proto"
+)
+
+// Codec converts the values of Namespace to and from bytes
+type Codec[T any] interface {
+	Marshal(v T) ([]byte, error)
+	Unmarshal(data []byte) (T, error)
+}
+
+// JSONCodec encodes the values with encoding/json
+type JSONCodec[T any] struct{}
+
+func (JSONCodec[T]) Marshal(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// GobCodec encodes the values with encoding/gob, every record is self contained, so it carries the type information
+type GobCodec[T any] struct{}
+
+func (GobCodec[T]) Marshal(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[T]) Unmarshal(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// ProtoCodec encodes protobuf messages, New must return new empty message to decode into
+// example:
+//
+//	ProtoCodec[*AppendInput]{New: func() *AppendInput { return &AppendInput{} }}
+type ProtoCodec[T proto.Message] struct {
+	New func() T
+}
+
+func (this ProtoCodec[T]) Marshal(v T) ([]byte, error) {
+	return proto.Marshal(v)
+}
+
+func (this ProtoCodec[T]) Unmarshal(data []byte) (T, error) {
+	v := this.New()
+	err := proto.Unmarshal(data, v)
+	return v, err
+}
+
+// Namespace is typed handle of a namespace, the values are converted with the codec
+// example:
+//
+//	events := NewNamespace[Event](r, "events", JSONCodec[Event]{}, func(e Event) []string { return []string{e.Type} })
+//	offset, err := events.Append(ctx, Event{Type: "click"})
+type Namespace[T any] struct {
+	client Interface
+	name   string
+	codec  Codec[T]
+	tags   func(T) []string
+}
+
+// NewNamespace binds the namespace to the client and the codec, tags (can be nil) derives the tags of the appended values
+func NewNamespace[T any](client Interface, name string, codec Codec[T], tags func(T) []string) *Namespace[T] {
+	return &Namespace[T]{
+		client: client,
+		name:   name,
+		codec:  codec,
+		tags:   tags,
+	}
+}
+
+// Name returns the name of the namespace
+func (this *Namespace[T]) Name() string {
+	return this.name
+}
+
+func (this *Namespace[T]) toAppend(v T) (*Append, error) {
+	data, err := this.codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	a := &Append{
+		Namespace: this.name,
+		Data:      data,
+	}
+	if this.tags != nil {
+		a.Tags = this.tags(v)
+	}
+	return a, nil
+}
+
+// Append stores the value, returns stored offset and error, see Client.Append
+func (this *Namespace[T]) Append(ctx context.Context, v T) (uint64, error) {
+	offsets, err := this.AppendMulti(ctx, []T{v})
+	if err != nil {
+		return 0, err
+	}
+	return offsets[0], nil
+}
+
+// AppendMulti stores the values in one round trip, the offsets are in the same order as the values
+func (this *Namespace[T]) AppendMulti(ctx context.Context, values []T) ([]uint64, error) {
+	input := &AppendInput{
+		AppendPayload: make([]*Append, len(values)),
+	}
+	for i, v := range values {
+		a, err := this.toAppend(v)
+		if err != nil {
+			return nil, err
+		}
+		input.AppendPayload[i] = a
+	}
+
+	out, err := this.client.SetContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Offset) != len(values) {
+		return nil, fmt.Errorf("expected %d offsets, but got: %d", len(values), len(out.Offset))
+	}
+	return out.Offset, nil
+}
+
+func (this *Namespace[T]) unmarshal(offset uint64, data []byte) (T, error) {
+	v, err := this.codec.Unmarshal(data)
+	if err != nil {
+		return v, &RecordError{Offset: offset, Err: err}
+	}
+	return v, nil
+}
+
+// Get fetches the value at offset
+func (this *Namespace[T]) Get(ctx context.Context, offset uint64) (T, error) {
+	data, err := this.client.GetOneContext(ctx, this.name, offset)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return this.unmarshal(offset, data)
+}
+
+// GetMulti fetches the values at the offsets in one round trip
+func (this *Namespace[T]) GetMulti(ctx context.Context, offsets []uint64) ([]T, error) {
+	data, err := this.client.GetMultiContext(ctx, this.name, offsets)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]T, len(data))
+	for i := range data {
+		out[i], err = this.unmarshal(offsets[i], data[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (this *Namespace[T]) callback(callback func(rochefortOffset uint64, v T) error) func(uint64, []byte) error {
+	return func(offset uint64, data []byte) error {
+		v, err := this.unmarshal(offset, data)
+		if err != nil {
+			return err
+		}
+		return callback(offset, v)
+	}
+}
+
+// Scan calls the callback for every value of the namespace, see Client.ScanFuncContext
+func (this *Namespace[T]) Scan(ctx context.Context, callback func(rochefortOffset uint64, v T) error) error {
+	return this.client.ScanFuncContext(ctx, this.name, this.callback(callback))
+}
+
+// Search calls the callback for every value matching the query, see Client.SearchFuncContext
+func (this *Namespace[T]) Search(ctx context.Context, query interface{}, callback func(rochefortOffset uint64, v T) error) error {
+	return this.client.SearchFuncContext(ctx, this.name, query, this.callback(callback))
+}
@@ -0,0 +1,95 @@
+package rochefort_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+type event struct {
+	Type  string
+	Value int
+}
+
+func TestNamespace(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+	ctx := context.Background()
+
+	for _, codec := range []rochefort.Codec[event]{rochefort.JSONCodec[event]{}, rochefort.GobCodec[event]{}} {
+		ns := rochefort.NewNamespace[event](r, "events", codec, func(e event) []string {
+			return []string{e.Type}
+		})
+
+		offsets, err := ns.AppendMulti(ctx, []event{{"click", 1}, {"view", 2}, {"click", 3}})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+
+		e, err := ns.Get(ctx, offsets[1])
+		if err != nil || e.Type != "view" || e.Value != 2 {
+			t.Logf("unexpected get: %+v, %v", e, err)
+			t.FailNow()
+		}
+
+		sum := 0
+		err = ns.Search(ctx, rochefort.Tag("click"), func(offset uint64, e event) error {
+			sum += e.Value
+			return nil
+		})
+		if err != nil || sum != 4 {
+			t.Logf("unexpected search: %d, %v", sum, err)
+			t.FailNow()
+		}
+
+		_, err = r.Delete(&rochefort.NamespaceInput{Namespace: "events"})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	ns := rochefort.NewNamespace[event](r, "mixed", rochefort.JSONCodec[event]{}, nil)
+	_, err := ns.Append(ctx, event{"a", 1})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	bad, err := r.Append("mixed", nil, 0, []byte("not json"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	err = ns.Scan(ctx, func(offset uint64, e event) error {
+		return nil
+	})
+	var recordErr *rochefort.RecordError
+	if !errors.As(err, &recordErr) || recordErr.Offset != bad {
+		t.Logf("expected RecordError, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestNamespaceProto(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	codec := rochefort.ProtoCodec[*rochefort.Get]{New: func() *rochefort.Get { return &rochefort.Get{} }}
+	ns := rochefort.NewNamespace[*rochefort.Get](r, "proto", codec, nil)
+	offset, err := ns.Append(context.Background(), &rochefort.Get{Namespace: "x", Offset: 42})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	v, err := ns.Get(context.Background(), offset)
+	if err != nil || v.Namespace != "x" || v.Offset != 42 {
+		t.Logf("unexpected get: %+v, %v", v, err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,183 @@
+package rochefort
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Event describes one finished Client operation, including all its retries
+type Event struct {
+	Operation Operation
+	// the namespace of the operation, empty if Set or Get touch more than one namespace
+	Namespace string
+	// number of appends and modifications for Set, requested records for Get, received records for Scan and Search
+	Payloads int
+	// request and response body bytes, summed over the retries
+	BytesSent     int64
+	BytesReceived int64
+	// status code of the last response, 0 if there was none
+	StatusCode int
+	// from the start of the operation until the result is returned, or for streams until the last record is read
+	Duration time.Duration
+	Err      error
+}
+
+// Observer is called after every Client operation, it is called synchronously so it should not block
+type Observer interface {
+	Observe(event Event)
+}
+
+// ObserverFunc adapts function to Observer
+type ObserverFunc func(event Event)
+
+func (this ObserverFunc) Observe(event Event) {
+	this(event)
+}
+
+// WithObserver adds observer to the client, e.g. NewPrometheusObserver
+func WithObserver(observer Observer) Option {
+	return func(o *options) {
+		o.observers = append(o.observers, observer)
+	}
+}
+
+// observation collects the event of one operation and holds its span, all methods are no-op on nil observation (client without observers and tracer)
+type observation struct {
+	observers []Observer
+	span      Span
+	event     Event
+	start     time.Time
+	done      bool
+}
+
+type observationKey struct{}
+
+// observe starts observation and span of the operation, the requests made with the returned context are counted in it
+func (this *Client) observe(ctx context.Context, op Operation, namespace string, payloads int) (context.Context, *observation) {
+	if len(this.observers) == 0 && this.tracer == nil {
+		return ctx, nil
+	}
+	o := &observation{
+		observers: this.observers,
+		event:     Event{Operation: op, Namespace: namespace, Payloads: payloads},
+		start:     time.Now(),
+	}
+	if this.tracer != nil {
+		ctx, o.span = this.tracer.StartSpan(ctx, "rochefort."+string(op),
+			Attribute{Key: "rochefort.operation", Value: string(op)},
+			Attribute{Key: "rochefort.namespace", Value: namespace},
+			Attribute{Key: "rochefort.payloads", Value: payloads},
+		)
+	}
+	return context.WithValue(ctx, observationKey{}, o), o
+}
+
+func observationFrom(ctx context.Context) *observation {
+	o, _ := ctx.Value(observationKey{}).(*observation)
+	return o
+}
+
+func (this *observation) sent(n int) {
+	if this != nil {
+		this.event.BytesSent += int64(n)
+	}
+}
+
+// traceParent returns the traceparent header value of the span, or empty string
+func (this *observation) traceParent() string {
+	if this == nil || this.span == nil {
+		return ""
+	}
+	return this.span.TraceParent()
+}
+
+func (this *observation) status(code int) {
+	if this != nil {
+		this.event.StatusCode = code
+	}
+}
+
+func (this *observation) record() {
+	if this != nil {
+		this.event.Payloads++
+	}
+}
+
+// body counts the bytes read from the response body
+func (this *observation) body(body io.ReadCloser) io.ReadCloser {
+	if this == nil {
+		return body
+	}
+	return &countingBody{ReadCloser: body, observation: this}
+}
+
+// finish reports the event to the observers and finishes the span, only the first call has effect
+func (this *observation) finish(err error) {
+	if this == nil || this.done {
+		return
+	}
+	this.done = true
+	this.event.Duration = time.Since(this.start)
+	this.event.Err = err
+	for _, observer := range this.observers {
+		observer.Observe(this.event)
+	}
+	if this.span != nil {
+		this.span.SetAttributes(
+			Attribute{Key: "http.response.status_code", Value: this.event.StatusCode},
+			Attribute{Key: "rochefort.payloads", Value: this.event.Payloads},
+			Attribute{Key: "rochefort.bytes_sent", Value: this.event.BytesSent},
+			Attribute{Key: "rochefort.bytes_received", Value: this.event.BytesReceived},
+		)
+		this.span.Finish(err)
+	}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	observation *observation
+}
+
+func (this *countingBody) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	this.observation.event.BytesReceived += int64(n)
+	return n, err
+}
+
+// inputNamespace returns the namespace shared by all payloads of the input, or empty string
+func inputNamespace(input *AppendInput) string {
+	namespace := ""
+	first := true
+	same := func(ns string) bool {
+		if first {
+			namespace, first = ns, false
+		}
+		return ns == namespace
+	}
+	for _, a := range input.AppendPayload {
+		if !same(a.Namespace) {
+			return ""
+		}
+	}
+	for _, m := range input.ModifyPayload {
+		if !same(m.Namespace) {
+			return ""
+		}
+	}
+	return namespace
+}
+
+// getNamespace returns the namespace shared by all payloads of the input, or empty string
+func getNamespace(input *GetInput) string {
+	if len(input.GetPayload) == 0 {
+		return ""
+	}
+	namespace := input.GetPayload[0].Namespace
+	for _, g := range input.GetPayload[1:] {
+		if g.Namespace != namespace {
+			return ""
+		}
+	}
+	return namespace
+}
@@ -0,0 +1,109 @@
+package rochefort_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+type recordingObserver struct {
+	lock   sync.Mutex
+	events []rochefort.Event
+}
+
+func (this *recordingObserver) Observe(event rochefort.Event) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.events = append(this.events, event)
+}
+
+func (this *recordingObserver) last(t *testing.T, op rochefort.Operation) rochefort.Event {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if len(this.events) == 0 || this.events[len(this.events)-1].Operation != op {
+		t.Logf("expected %s event, got: %+v", op, this.events)
+		t.FailNow()
+	}
+	return this.events[len(this.events)-1]
+}
+
+func TestObserver(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	observer := &recordingObserver{}
+	r := rochefort.New(server.URL, rochefort.WithObserver(observer))
+	ctx := context.Background()
+
+	_, err := r.Set(&rochefort.AppendInput{AppendPayload: []*rochefort.Append{
+		{Namespace: "events", Tags: []string{"a"}, Data: []byte("hello")},
+		{Namespace: "events", Data: []byte("world")},
+	}})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	e := observer.last(t, rochefort.OpSet)
+	if e.Namespace != "events" || e.Payloads != 2 || e.StatusCode != 200 || e.BytesSent == 0 || e.BytesReceived == 0 || e.Duration <= 0 || e.Err != nil {
+		t.Logf("unexpected set event: %+v", e)
+		t.FailNow()
+	}
+
+	_, err = r.Get(&rochefort.GetInput{GetPayload: []*rochefort.Get{{Namespace: "events", Offset: 0}, {Namespace: "other", Offset: 0}}})
+	e = observer.last(t, rochefort.OpGet)
+	if err == nil || e.Namespace != "" || e.Payloads != 2 || e.StatusCode != 404 || !errors.Is(e.Err, rochefort.ErrNotFound) {
+		t.Logf("unexpected get event: %+v", e)
+		t.FailNow()
+	}
+
+	err = r.Scan("events", func(offset uint64, data []byte) {})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	e = observer.last(t, rochefort.OpScan)
+	if e.Namespace != "events" || e.Payloads != 2 || e.BytesReceived != 2*(12+5) {
+		t.Logf("unexpected scan event: %+v", e)
+		t.FailNow()
+	}
+
+	it, err := r.SearchIterator(ctx, "events", rochefort.Tag("a"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	for it.Next() {
+	}
+	e = observer.last(t, rochefort.OpSearch)
+	if e.Payloads != 1 || e.BytesSent == 0 || e.BytesReceived != 12+5 || e.Err != nil {
+		t.Logf("unexpected search event: %+v", e)
+		t.FailNow()
+	}
+
+	server.FailNext("stat", 1, 503)
+	_, err = r.Stats("events")
+	e = observer.last(t, rochefort.OpStats)
+	if err == nil || e.StatusCode != 503 || e.Err != err {
+		t.Logf("unexpected stats event: %+v", e)
+		t.FailNow()
+	}
+
+	_, err = r.Delete(&rochefort.NamespaceInput{Namespace: "events"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	e = observer.last(t, rochefort.OpDelete)
+	if e.Namespace != "events" || e.StatusCode != 200 {
+		t.Logf("unexpected delete event: %+v", e)
+		t.FailNow()
+	}
+	if len(observer.events) != 6 {
+		t.Logf("expected 6 events, got: %d", len(observer.events))
+		t.FailNow()
+	}
+}
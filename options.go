@@ -0,0 +1,179 @@
+package rochefort
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrIdleTimeout is returned when Scan or Search does not receive any data for longer than the stream idle timeout
+var ErrIdleTimeout = errors.New("stream idle timeout")
+
+type options struct {
+	httpClient          *http.Client
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	timeouts            map[Operation]time.Duration
+	streamIdleTimeout   time.Duration
+	userAgent           string
+	headers             http.Header
+	retry               *RetryPolicy
+	codecs              []PayloadCodec
+	observers           []Observer
+	tracer              Tracer
+	interceptors        []Interceptor
+}
+
+// Option configures the Client created by New
+type Option func(*options)
+
+// WithHTTPClient uses the given http client, in which case WithMaxIdleConnsPerHost and WithMaxConnsPerHost are ignored
+// keep in mind that http.Client.Timeout includes reading the body, so it also limits Scan and Search
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithMaxIdleConnsPerHost sets the size of the idle connection pool of the transport, default 64
+func WithMaxIdleConnsPerHost(n int) Option {
+	return func(o *options) {
+		o.maxIdleConnsPerHost = n
+	}
+}
+
+// WithMaxConnsPerHost limits the total number of connections to the server, default 0 (unlimited)
+func WithMaxConnsPerHost(n int) Option {
+	return func(o *options) {
+		o.maxConnsPerHost = n
+	}
+}
+
+// WithTimeout sets the timeout of the operation including the retries, 0 means no timeout
+// for Scan and Search it limits the whole stream, use WithStreamIdleTimeout to limit the time between the reads instead
+func WithTimeout(op Operation, timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeouts[op] = timeout
+	}
+}
+
+// WithStreamIdleTimeout aborts Scan and Search with ErrIdleTimeout if no data is received for the given duration, 0 disables it
+func WithStreamIdleTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.streamIdleTimeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithHeader adds header to every request
+func WithHeader(key string, value string) Option {
+	return func(o *options) {
+		o.headers.Add(key, value)
+	}
+}
+
+// WithRetryPolicy enables retries of the idempotent operations, see SetRetryPolicy
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// New creates new client with options, the defaults are:
+// 1 second timeout for Set, Get and Stats, no timeout for Compact, Delete, Scan and Search,
+// 30 seconds stream idle timeout for Scan and Search, and 64 idle connections per host
+func New(url string, opts ...Option) *Client {
+	o := &options{
+		maxIdleConnsPerHost: 64,
+		timeouts: map[Operation]time.Duration{
+			OpSet:   time.Second,
+			OpGet:   time.Second,
+			OpStats: time.Second,
+		},
+		streamIdleTimeout: 30 * time.Second,
+		headers:           http.Header{},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.httpClient == nil {
+		o.httpClient = &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				MaxIdleConnsPerHost: o.maxIdleConnsPerHost,
+				MaxConnsPerHost:     o.maxConnsPerHost,
+			},
+		}
+	}
+	return newClient(url, o)
+}
+
+// withTimeout applies the timeout of the operation to ctx
+func (this *Client) withTimeout(ctx context.Context, op Operation) (context.Context, context.CancelFunc) {
+	if timeout := this.timeouts[op]; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// streamBody releases the context of the streaming request when closed, and cancels it if a single read waits for longer than the idle timeout
+// the time spent between the reads (e.g. in the Scan callback) does not count
+type streamBody struct {
+	io.ReadCloser
+	cancel  context.CancelFunc
+	idle    time.Duration
+	timer   *time.Timer
+	lock    sync.Mutex
+	expired bool
+}
+
+func newStreamBody(body io.ReadCloser, cancel context.CancelFunc, idle time.Duration) *streamBody {
+	this := &streamBody{ReadCloser: body, cancel: cancel, idle: idle}
+	if idle > 0 {
+		this.timer = time.AfterFunc(idle, func() {
+			this.lock.Lock()
+			this.expired = true
+			this.lock.Unlock()
+			cancel()
+		})
+		this.timer.Stop()
+	}
+	return this
+}
+
+func (this *streamBody) Read(p []byte) (int, error) {
+	if this.timer == nil {
+		return this.ReadCloser.Read(p)
+	}
+
+	this.timer.Reset(this.idle)
+	n, err := this.ReadCloser.Read(p)
+	this.timer.Stop()
+
+	this.lock.Lock()
+	expired := this.expired
+	this.lock.Unlock()
+	if expired {
+		return n, ErrIdleTimeout
+	}
+	return n, err
+}
+
+func (this *streamBody) Close() error {
+	if this.timer != nil {
+		this.timer.Stop()
+	}
+	err := this.ReadCloser.Close()
+	this.cancel()
+	return err
+}
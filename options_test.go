@@ -0,0 +1,61 @@
+package rochefort
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOptionsHeaders(t *testing.T) {
+	var userAgent, tenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		tenant = r.Header.Get("X-Tenant")
+	}))
+	defer server.Close()
+
+	r := New(server.URL, WithUserAgent("test-agent"), WithHeader("X-Tenant", "abc"))
+	_, err := r.Stats("ns")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if userAgent != "test-agent" || tenant != "abc" {
+		t.Logf("unexpected headers: %s, %s", userAgent, tenant)
+		t.FailNow()
+	}
+}
+
+func TestOptionsTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/scan" {
+			w.Write([]byte{3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 'a', 'b', 'c'})
+			w.(http.Flusher).Flush()
+		}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	r := New(server.URL, WithTimeout(OpGet, 20*time.Millisecond), WithStreamIdleTimeout(20*time.Millisecond))
+	_, err := r.Get(&GetInput{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Logf("expected deadline exceeded, got: %v", err)
+		t.FailNow()
+	}
+
+	seen := 0
+	err = r.Scan("ns", func(offset uint64, data []byte) {
+		// slow consumers do not count towards the idle timeout
+		time.Sleep(40 * time.Millisecond)
+		seen++
+	})
+	if !errors.Is(err, ErrIdleTimeout) || seen != 1 {
+		t.Logf("expected idle timeout after 1 record, got: %d, %v", seen, err)
+		t.FailNow()
+	}
+}
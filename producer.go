@@ -0,0 +1,438 @@
+package rochefort
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull is returned by Producer.Send when the queue is full and the policy is FullError
+var ErrQueueFull = errors.New("queue full")
+
+// FullPolicy decides what Producer.Send does when the queue is full
+type FullPolicy int
+
+const (
+	// block until there is space in the queue or the context is done
+	FullBlock FullPolicy = iota
+	// drop the append, it is counted in Producer.Dropped
+	FullDrop
+	// return ErrQueueFull
+	FullError
+)
+
+// ProducerConfig controls the memory, batching and retries of Producer
+type ProducerConfig struct {
+	// maximum number of appends queued or in flight, default 1000
+	QueueSize int
+	// maximum sum of the data sizes queued or in flight, default 64MB, a single append bigger than that is accepted only when the queue is empty
+	QueueBytes int
+	// what to do when the queue is full, default FullBlock
+	OnFull FullPolicy
+	// batching of the appends into one AppendInput, see BatchConfig
+	Batch BatchConfig
+	// how many times a failed batch is retried before its appends are reported as failed, default 3
+	// only the errors accepted by the retry policy of the client (or DefaultRetryable) are retried, e.g. 4xx errors are reported right away
+	MaxRetries int
+	// backoff between the retries, default 100ms
+	RetryBackoff time.Duration
+	// deliver the stored appends on Successes, which then must be drained
+	ReturnSuccesses bool
+	// deliver the failed appends on Errors, which then must be drained, otherwise they are returned by Flush and Close in *UndeliveredError
+	ReturnErrors bool
+}
+
+func (this ProducerConfig) withDefaults() ProducerConfig {
+	if this.QueueSize <= 0 {
+		this.QueueSize = 1000
+	}
+	if this.QueueBytes <= 0 {
+		this.QueueBytes = 64 * 1024 * 1024
+	}
+	if this.MaxRetries < 0 {
+		this.MaxRetries = 0
+	} else if this.MaxRetries == 0 {
+		this.MaxRetries = 3
+	}
+	if this.RetryBackoff <= 0 {
+		this.RetryBackoff = 100 * time.Millisecond
+	}
+	this.Batch = this.Batch.withDefaults()
+	return this
+}
+
+// ProducerResult is the outcome of one append sent with Producer.Send
+type ProducerResult struct {
+	Append *Append
+	// the stored offset, valid only if Err is nil
+	Offset uint64
+	Err    error
+}
+
+// UndeliveredError is returned by Producer.Flush and Producer.Close when some appends failed after all the retries (and ReturnErrors is not set), or by Close when it could not deliver all the queued appends before the context was done
+type UndeliveredError struct {
+	Appends []*Append
+	Err     error
+}
+
+func (this *UndeliveredError) Error() string {
+	return fmt.Sprintf("%d appends were not delivered, error: %s", len(this.Appends), this.Err.Error())
+}
+
+func (this *UndeliveredError) Unwrap() error {
+	return this.Err
+}
+
+// Producer is fire-and-forget appender with bounded queue, it sends the queued appends in batches from a background goroutine
+// failed batches are retried, so an append can be stored more than once (at least once delivery)
+// example:
+//
+//	p := NewProducer(r, ProducerConfig{ReturnErrors: true})
+//	go func() {
+//		for result := range p.Errors() {
+//			log.Printf("failed to store: %s", result.Err)
+//		}
+//	}()
+//	err := p.Send(ctx, &Append{Namespace: ns, Data: data})
+//	...
+//	err = p.Close(ctx)
+type Producer struct {
+	client Interface
+	config ProducerConfig
+
+	lock     sync.Mutex
+	changed  chan struct{}
+	queue    []*Append
+	inflight []*Append
+	// the appends that failed since the last Flush or Close, and the last error, kept only without ReturnErrors
+	failed    []*Append
+	failedErr error
+	bytes     int
+	enqueued  uint64
+	done      uint64
+	flushing  int
+	dropped   uint64
+	closed    bool
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	stopped   chan struct{}
+	successes chan *ProducerResult
+	errors    chan *ProducerResult
+}
+
+// NewProducer starts the producer, it must be closed to deliver the remaining appends and release the goroutine
+func NewProducer(client Interface, config ProducerConfig) *Producer {
+	config = config.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	this := &Producer{
+		client:    client,
+		config:    config,
+		changed:   make(chan struct{}),
+		ctx:       ctx,
+		cancel:    cancel,
+		stopped:   make(chan struct{}),
+		successes: make(chan *ProducerResult, config.QueueSize),
+		errors:    make(chan *ProducerResult, config.QueueSize),
+	}
+	go this.run()
+	return this
+}
+
+// Successes returns the stored appends if ReturnSuccesses is set, it is closed when the producer stops
+func (this *Producer) Successes() <-chan *ProducerResult {
+	return this.successes
+}
+
+// Errors returns the appends that failed after all the retries if ReturnErrors is set, it is closed when the producer stops
+func (this *Producer) Errors() <-chan *ProducerResult {
+	return this.errors
+}
+
+// Dropped returns the number of appends dropped because the queue was full (with FullDrop policy)
+func (this *Producer) Dropped() uint64 {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.dropped
+}
+
+// broadcastLocked wakes up everybody waiting for a change, must be called with the lock held
+func (this *Producer) broadcastLocked() {
+	close(this.changed)
+	this.changed = make(chan struct{})
+}
+
+func (this *Producer) fullLocked(size int) bool {
+	queued := len(this.queue) + len(this.inflight)
+	if queued == 0 {
+		return false
+	}
+	return queued >= this.config.QueueSize || this.bytes+size > this.config.QueueBytes
+}
+
+// Send queues the append, when the queue is full it blocks, drops or fails according to the OnFull policy
+// with FullBlock it returns ctx.Err() if ctx is done before there is space in the queue
+func (this *Producer) Send(ctx context.Context, a *Append) error {
+	this.lock.Lock()
+	for {
+		if this.closed {
+			this.lock.Unlock()
+			return ErrClosed
+		}
+		if !this.fullLocked(len(a.Data)) {
+			break
+		}
+
+		switch this.config.OnFull {
+		case FullDrop:
+			this.dropped++
+			this.lock.Unlock()
+			return nil
+		case FullError:
+			this.lock.Unlock()
+			return ErrQueueFull
+		}
+
+		changed := this.changed
+		this.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+		this.lock.Lock()
+	}
+
+	this.queue = append(this.queue, a)
+	this.bytes += len(a.Data)
+	this.enqueued++
+	this.broadcastLocked()
+	this.lock.Unlock()
+	return nil
+}
+
+// Flush waits until all the appends sent so far are either stored or reported as failed, or ctx is done
+// without ReturnErrors the appends that failed since the last Flush are returned in *UndeliveredError
+func (this *Producer) Flush(ctx context.Context) error {
+	this.lock.Lock()
+	target := this.enqueued
+	this.flushing++
+	this.broadcastLocked()
+	defer func() {
+		this.lock.Lock()
+		this.flushing--
+		this.lock.Unlock()
+	}()
+
+	for this.done < target {
+		changed := this.changed
+		this.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-this.stopped:
+			this.lock.Lock()
+			if this.done < target {
+				this.lock.Unlock()
+				return ErrClosed
+			}
+			continue
+		case <-changed:
+		}
+		this.lock.Lock()
+	}
+	err := this.takeFailedLocked()
+	this.lock.Unlock()
+	return err
+}
+
+// takeFailedLocked returns the failed appends in *UndeliveredError and forgets them, or nil if there are none, must be called with the lock held
+func (this *Producer) takeFailedLocked() error {
+	if len(this.failed) == 0 {
+		return nil
+	}
+	err := &UndeliveredError{Appends: this.failed, Err: this.failedErr}
+	this.failed = nil
+	this.failedErr = nil
+	return err
+}
+
+// Close stops accepting appends and delivers the queued ones, if ctx is done first the delivery is aborted and the appends that were not delivered are returned in *UndeliveredError
+// without ReturnErrors the appends that failed since the last Flush are returned in *UndeliveredError as well
+func (this *Producer) Close(ctx context.Context) error {
+	this.lock.Lock()
+	if !this.closed {
+		this.closed = true
+		this.broadcastLocked()
+	}
+	this.lock.Unlock()
+
+	select {
+	case <-this.stopped:
+		this.lock.Lock()
+		defer this.lock.Unlock()
+		return this.takeFailedLocked()
+	case <-ctx.Done():
+	}
+
+	this.cancel()
+	<-this.stopped
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	undelivered := append(append(append([]*Append{}, this.failed...), this.inflight...), this.queue...)
+	if len(undelivered) == 0 {
+		return nil
+	}
+	return &UndeliveredError{Appends: undelivered, Err: ctx.Err()}
+}
+
+func (this *Producer) run() {
+	defer close(this.stopped)
+	defer close(this.errors)
+	defer close(this.successes)
+
+	for {
+		batch, ok := this.next()
+		if !ok {
+			return
+		}
+
+		results, ok := this.send(batch)
+
+		this.lock.Lock()
+		if !ok {
+			// aborted by Close, the batch stays in inflight to be reported as undelivered
+			this.lock.Unlock()
+			return
+		}
+		for _, a := range batch {
+			this.bytes -= len(a.Data)
+		}
+		if len(results) > 0 && results[0].Err != nil && !this.config.ReturnErrors {
+			this.failed = append(this.failed, batch...)
+			this.failedErr = results[0].Err
+		}
+		this.inflight = nil
+		this.done += uint64(len(batch))
+		this.broadcastLocked()
+		this.lock.Unlock()
+
+		for _, result := range results {
+			var out chan *ProducerResult
+			if result.Err == nil && this.config.ReturnSuccesses {
+				out = this.successes
+			} else if result.Err != nil && this.config.ReturnErrors {
+				out = this.errors
+			} else {
+				continue
+			}
+
+			select {
+			case out <- result:
+			case <-this.ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// next waits for the next batch, lingering for more appends unless the producer is closing or flushing, returns false when the producer is done
+func (this *Producer) next() ([]*Append, bool) {
+	var linger <-chan time.Time
+	expired := false
+
+	this.lock.Lock()
+	for {
+		if len(this.queue) == 0 {
+			if this.closed {
+				this.lock.Unlock()
+				return nil, false
+			}
+			linger = nil
+			expired = false
+		} else if expired || this.closed || this.flushing > 0 || len(this.queue) >= this.config.Batch.MaxCount || this.queuedBytesLocked() >= this.config.Batch.MaxBytes {
+			break
+		} else if linger == nil {
+			linger = time.After(this.config.Batch.Linger)
+		}
+
+		changed := this.changed
+		this.lock.Unlock()
+		select {
+		case <-this.ctx.Done():
+			return nil, false
+		case <-linger:
+			expired = true
+		case <-changed:
+		}
+		this.lock.Lock()
+	}
+
+	n, size := 0, 0
+	for n < len(this.queue) && n < this.config.Batch.MaxCount {
+		if n > 0 && size+len(this.queue[n].Data) > this.config.Batch.MaxBytes {
+			break
+		}
+		size += len(this.queue[n].Data)
+		n++
+	}
+	batch := this.queue[:n:n]
+	this.queue = this.queue[n:]
+	this.inflight = batch
+	this.lock.Unlock()
+	return batch, true
+}
+
+// queuedBytesLocked returns the data size of the queued (not in flight) appends, must be called with the lock held
+func (this *Producer) queuedBytesLocked() int {
+	size := 0
+	for _, a := range this.queue {
+		size += len(a.Data)
+	}
+	return size
+}
+
+// send stores the batch with retries, returns false if it was aborted by Close
+func (this *Producer) send(batch []*Append) ([]*ProducerResult, bool) {
+	input := &AppendInput{AppendPayload: batch}
+
+	var err error
+	for attempt := 0; attempt <= this.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-this.ctx.Done():
+				return nil, false
+			case <-time.After(this.config.RetryBackoff):
+			}
+		}
+
+		var out *AppendOutput
+		out, err = this.client.SetContext(this.ctx, input)
+		if this.ctx.Err() != nil {
+			return nil, false
+		}
+		if err == nil && len(out.Offset) != len(batch) {
+			err = fmt.Errorf("expected %d offsets, but got: %d", len(batch), len(out.Offset))
+		}
+		if err == nil {
+			results := make([]*ProducerResult, len(batch))
+			for i, a := range batch {
+				results[i] = &ProducerResult{Append: a, Offset: out.Offset[i]}
+			}
+			return results, true
+		}
+		if !retryable(this.client, err) {
+			break
+		}
+	}
+
+	results := make([]*ProducerResult, len(batch))
+	for i, a := range batch {
+		results[i] = &ProducerResult{Append: a, Err: err}
+	}
+	return results, true
+}
@@ -0,0 +1,219 @@
+package rochefort_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestProducer(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	p := rochefort.NewProducer(r, rochefort.ProducerConfig{
+		QueueSize:       10,
+		ReturnSuccesses: true,
+		ReturnErrors:    true,
+		RetryBackoff:    time.Millisecond,
+		Batch:           rochefort.BatchConfig{MaxCount: 5, Linger: time.Millisecond},
+	})
+
+	stored := map[string]uint64{}
+	collected := make(chan struct{})
+	go func() {
+		for result := range p.Successes() {
+			stored[string(result.Append.Data)] = result.Offset
+		}
+		close(collected)
+	}()
+	go func() {
+		for result := range p.Errors() {
+			t.Errorf("unexpected failure: %v", result.Err)
+		}
+	}()
+
+	server.FailNext("set", 2, 503)
+	for i := 0; i < 50; i++ {
+		err := p.Send(context.Background(), &rochefort.Append{
+			Namespace: "producer",
+			Data:      []byte(fmt.Sprintf("value-%d", i)),
+		})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+	}
+
+	err := p.Flush(context.Background())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	<-collected
+
+	if len(stored) != 50 {
+		t.Logf("expected 50 stored appends, got: %d", len(stored))
+		t.FailNow()
+	}
+	for value, offset := range stored {
+		data, err := r.GetOne("producer", offset)
+		if err != nil || string(data) != value {
+			t.Logf("unexpected read at %d: %s, %v", offset, string(data), err)
+			t.FailNow()
+		}
+	}
+
+	if p.Send(context.Background(), &rochefort.Append{}) != rochefort.ErrClosed {
+		t.Log("expected ErrClosed after Close")
+		t.FailNow()
+	}
+}
+
+func TestProducerNotRetryable(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	p := rochefort.NewProducer(r, rochefort.ProducerConfig{
+		ReturnErrors: true,
+		RetryBackoff: time.Hour,
+		Batch:        rochefort.BatchConfig{Linger: time.Millisecond},
+	})
+
+	server.FailNext("set", 1, 400)
+	err := p.Send(context.Background(), &rochefort.Append{Namespace: "producer", Data: []byte("rejected")})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	// with the hour long backoff the failure arrives in time only if it is not retried
+	select {
+	case result := <-p.Errors():
+		if !errors.Is(result.Err, rochefort.ErrBadRequest) || server.Requests("set") != 1 {
+			t.Logf("expected single rejected attempt, got: %v, requests: %d", result.Err, server.Requests("set"))
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Log("expected the 400 error to be reported without retries")
+		t.FailNow()
+	}
+
+	err = p.Close(context.Background())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+}
+
+func TestProducerFull(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	// the server keeps failing, so nothing leaves the queue
+	server.FailNext("set", 1000, 503)
+
+	for _, policy := range []rochefort.FullPolicy{rochefort.FullBlock, rochefort.FullDrop, rochefort.FullError} {
+		p := rochefort.NewProducer(r, rochefort.ProducerConfig{
+			QueueSize:    2,
+			OnFull:       policy,
+			RetryBackoff: time.Hour,
+		})
+
+		for i := 0; i < 2; i++ {
+			if err := p.Send(context.Background(), &rochefort.Append{Namespace: "full"}); err != nil {
+				t.Log(err)
+				t.FailNow()
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := p.Send(ctx, &rochefort.Append{Namespace: "full"})
+		cancel()
+
+		switch policy {
+		case rochefort.FullBlock:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Logf("expected blocked send to time out, got: %v", err)
+				t.FailNow()
+			}
+		case rochefort.FullDrop:
+			if err != nil || p.Dropped() != 1 {
+				t.Logf("expected dropped append, got: %d, %v", p.Dropped(), err)
+				t.FailNow()
+			}
+		case rochefort.FullError:
+			if err != rochefort.ErrQueueFull {
+				t.Logf("expected ErrQueueFull, got: %v", err)
+				t.FailNow()
+			}
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err = p.Close(ctx)
+		cancel()
+
+		var undelivered *rochefort.UndeliveredError
+		if !errors.As(err, &undelivered) || len(undelivered.Appends) != 2 {
+			t.Logf("expected 2 undelivered appends, got: %v", err)
+			t.FailNow()
+		}
+	}
+}
+
+func TestProducerUndelivered(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	p := rochefort.NewProducer(r, rochefort.ProducerConfig{
+		RetryBackoff: time.Millisecond,
+		Batch:        rochefort.BatchConfig{Linger: time.Millisecond},
+	})
+
+	server.FailNext("set", 1, 400)
+	err := p.Send(context.Background(), &rochefort.Append{Namespace: "producer", Data: []byte("rejected")})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	err = p.Flush(context.Background())
+	var undelivered *rochefort.UndeliveredError
+	if !errors.As(err, &undelivered) || len(undelivered.Appends) != 1 || !errors.Is(err, rochefort.ErrBadRequest) {
+		t.Logf("expected the rejected append from Flush, got: %v", err)
+		t.FailNow()
+	}
+
+	// the failures are reported once, the next ones by Close
+	err = p.Flush(context.Background())
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	server.FailNext("set", 1, 400)
+	err = p.Send(context.Background(), &rochefort.Append{Namespace: "producer", Data: []byte("rejected")})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	err = p.Close(context.Background())
+	if !errors.As(err, &undelivered) || len(undelivered.Appends) != 1 || string(undelivered.Appends[0].Data) != "rejected" {
+		t.Logf("expected the rejected append from Close, got: %v", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,156 @@
+package rochefort
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultBuckets are the latency histogram buckets of NewPrometheusObserver, in seconds
+var DefaultBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// PrometheusObserver aggregates the events per operation and exposes them in the prometheus text format, without depending on the prometheus client library
+// example:
+//
+//	metrics := rochefort.NewPrometheusObserver(nil)
+//	client := rochefort.New(url, rochefort.WithObserver(metrics))
+//	http.Handle("/metrics", metrics)
+type PrometheusObserver struct {
+	buckets    []float64
+	lock       sync.Mutex
+	operations map[Operation]*operationMetrics
+}
+
+type operationMetrics struct {
+	// cumulative counts are computed on write, counts[i] is the number of durations in (buckets[i-1], buckets[i]], the last one is +Inf
+	counts        []uint64
+	sum           float64
+	requests      uint64
+	errors        uint64
+	payloads      uint64
+	bytesSent     uint64
+	bytesReceived uint64
+}
+
+// NewPrometheusObserver creates the observer with the given latency buckets in seconds, nil means DefaultBuckets
+func NewPrometheusObserver(buckets []float64) *PrometheusObserver {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &PrometheusObserver{
+		buckets:    buckets,
+		operations: map[Operation]*operationMetrics{},
+	}
+}
+
+func (this *PrometheusObserver) Observe(event Event) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	m, ok := this.operations[event.Operation]
+	if !ok {
+		m = &operationMetrics{counts: make([]uint64, len(this.buckets)+1)}
+		this.operations[event.Operation] = m
+	}
+
+	seconds := event.Duration.Seconds()
+	m.counts[sort.SearchFloat64s(this.buckets, seconds)]++
+	m.sum += seconds
+	m.requests++
+	if event.Err != nil {
+		m.errors++
+	}
+	m.payloads += uint64(event.Payloads)
+	m.bytesSent += uint64(event.BytesSent)
+	m.bytesReceived += uint64(event.BytesReceived)
+}
+
+// WriteTo writes all metrics in the prometheus text exposition format, the operations are sorted by name
+func (this *PrometheusObserver) WriteTo(w io.Writer) (int64, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	operations := make([]string, 0, len(this.operations))
+	for op := range this.operations {
+		operations = append(operations, string(op))
+	}
+	sort.Strings(operations)
+
+	out := &countingWriter{w: bufio.NewWriter(w)}
+
+	fmt.Fprintf(out, "# HELP rochefort_client_duration_seconds Duration of the rochefort client operations.\n")
+	fmt.Fprintf(out, "# TYPE rochefort_client_duration_seconds histogram\n")
+	for _, op := range operations {
+		m := this.operations[Operation(op)]
+		cumulative := uint64(0)
+		for i, le := range this.buckets {
+			cumulative += m.counts[i]
+			fmt.Fprintf(out, "rochefort_client_duration_seconds_bucket{operation=%q,le=%q} %d\n", op, formatFloat(le), cumulative)
+		}
+		cumulative += m.counts[len(this.buckets)]
+		fmt.Fprintf(out, "rochefort_client_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n", op, cumulative)
+		fmt.Fprintf(out, "rochefort_client_duration_seconds_sum{operation=%q} %s\n", op, formatFloat(m.sum))
+		fmt.Fprintf(out, "rochefort_client_duration_seconds_count{operation=%q} %d\n", op, m.requests)
+	}
+
+	counters := []struct {
+		name  string
+		help  string
+		value func(m *operationMetrics) uint64
+	}{
+		{"rochefort_client_requests_total", "Number of the rochefort client operations.", func(m *operationMetrics) uint64 { return m.requests }},
+		{"rochefort_client_errors_total", "Number of the failed rochefort client operations.", func(m *operationMetrics) uint64 { return m.errors }},
+		{"rochefort_client_payloads_total", "Number of the records sent or received by the rochefort client operations.", func(m *operationMetrics) uint64 { return m.payloads }},
+		{"rochefort_client_sent_bytes_total", "Request body bytes sent by the rochefort client.", func(m *operationMetrics) uint64 { return m.bytesSent }},
+		{"rochefort_client_received_bytes_total", "Response body bytes received by the rochefort client.", func(m *operationMetrics) uint64 { return m.bytesReceived }},
+	}
+	for _, c := range counters {
+		fmt.Fprintf(out, "# HELP %s %s\n", c.name, c.help)
+		fmt.Fprintf(out, "# TYPE %s counter\n", c.name)
+		for _, op := range operations {
+			fmt.Fprintf(out, "%s{operation=%q} %d\n", c.name, op, c.value(this.operations[Operation(op)]))
+		}
+	}
+
+	if out.err != nil {
+		return out.n, out.err
+	}
+	return out.n, out.w.Flush()
+}
+
+// ServeHTTP serves the metrics, so the observer can be registered as /metrics handler
+func (this *PrometheusObserver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	this.WriteTo(w)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter remembers the first error, so the writes can be checked once at the end
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (this *countingWriter) Write(p []byte) (int, error) {
+	if this.err != nil {
+		return 0, this.err
+	}
+	n, err := this.w.Write(p)
+	this.n += int64(n)
+	this.err = err
+	return n, err
+}
@@ -0,0 +1,62 @@
+package rochefort_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+)
+
+func TestPrometheusObserver(t *testing.T) {
+	metrics := rochefort.NewPrometheusObserver([]float64{0.1, 0.01})
+	metrics.Observe(rochefort.Event{Operation: rochefort.OpSet, Payloads: 2, BytesSent: 100, BytesReceived: 4, Duration: 5 * time.Millisecond})
+	metrics.Observe(rochefort.Event{Operation: rochefort.OpSet, Payloads: 1, BytesSent: 50, BytesReceived: 2, Duration: 50 * time.Millisecond, Err: errors.New("failed")})
+	metrics.Observe(rochefort.Event{Operation: rochefort.OpGet, Payloads: 3, BytesSent: 10, BytesReceived: 300, Duration: time.Second})
+
+	var out bytes.Buffer
+	n, err := metrics.WriteTo(&out)
+	if err != nil || n != int64(out.Len()) {
+		t.Log(n, err)
+		t.FailNow()
+	}
+
+	expected := `# HELP rochefort_client_duration_seconds Duration of the rochefort client operations.
+# TYPE rochefort_client_duration_seconds histogram
+rochefort_client_duration_seconds_bucket{operation="get",le="0.01"} 0
+rochefort_client_duration_seconds_bucket{operation="get",le="0.1"} 0
+rochefort_client_duration_seconds_bucket{operation="get",le="+Inf"} 1
+rochefort_client_duration_seconds_sum{operation="get"} 1
+rochefort_client_duration_seconds_count{operation="get"} 1
+rochefort_client_duration_seconds_bucket{operation="set",le="0.01"} 1
+rochefort_client_duration_seconds_bucket{operation="set",le="0.1"} 2
+rochefort_client_duration_seconds_bucket{operation="set",le="+Inf"} 2
+rochefort_client_duration_seconds_sum{operation="set"} 0.055
+rochefort_client_duration_seconds_count{operation="set"} 2
+# HELP rochefort_client_requests_total Number of the rochefort client operations.
+# TYPE rochefort_client_requests_total counter
+rochefort_client_requests_total{operation="get"} 1
+rochefort_client_requests_total{operation="set"} 2
+# HELP rochefort_client_errors_total Number of the failed rochefort client operations.
+# TYPE rochefort_client_errors_total counter
+rochefort_client_errors_total{operation="get"} 0
+rochefort_client_errors_total{operation="set"} 1
+# HELP rochefort_client_payloads_total Number of the records sent or received by the rochefort client operations.
+# TYPE rochefort_client_payloads_total counter
+rochefort_client_payloads_total{operation="get"} 3
+rochefort_client_payloads_total{operation="set"} 3
+# HELP rochefort_client_sent_bytes_total Request body bytes sent by the rochefort client.
+# TYPE rochefort_client_sent_bytes_total counter
+rochefort_client_sent_bytes_total{operation="get"} 10
+rochefort_client_sent_bytes_total{operation="set"} 150
+# HELP rochefort_client_received_bytes_total Response body bytes received by the rochefort client.
+# TYPE rochefort_client_received_bytes_total counter
+rochefort_client_received_bytes_total{operation="get"} 300
+rochefort_client_received_bytes_total{operation="set"} 6
+`
+	if out.String() != expected {
+		t.Logf("unexpected output:\n%s", out.String())
+		t.FailNow()
+	}
+}
@@ -0,0 +1,277 @@
+package rochefort
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Query is a typed search expression for Search, build it with Tag, And, Or and Not
+// example:
+//
+//	r.Search(ns, Or(Tag("a"), And(Tag("b"), Not(Tag("c")))), callback)
+type Query interface {
+	// Validate checks the query on the client, before it is sent to the server
+	Validate() error
+	json.Marshaler
+}
+
+var ErrEmptyQuery = errors.New("empty query")
+
+type tagQuery struct {
+	tag string
+}
+
+// Tag matches all blobs appended with the tag
+func Tag(tag string) Query {
+	return &tagQuery{tag: tag}
+}
+
+func (this *tagQuery) Validate() error {
+	if this.tag == "" {
+		return errors.New("tag query with empty tag")
+	}
+	return nil
+}
+
+func (this *tagQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"tag": this.tag})
+}
+
+type boolQuery struct {
+	op      string
+	queries []Query
+}
+
+// And matches the blobs matched by all the queries
+func And(queries ...Query) Query {
+	return &boolQuery{op: "and", queries: queries}
+}
+
+// Or matches the blobs matched by any of the queries
+func Or(queries ...Query) Query {
+	return &boolQuery{op: "or", queries: queries}
+}
+
+func (this *boolQuery) Validate() error {
+	if len(this.queries) == 0 {
+		return fmt.Errorf("%s query without subqueries", this.op)
+	}
+	for i, q := range this.queries {
+		if q == nil {
+			return fmt.Errorf("%s query with nil subquery at position %d", this.op, i)
+		}
+		if err := q.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *boolQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]Query{this.op: this.queries})
+}
+
+type notQuery struct {
+	query Query
+}
+
+// Not matches the blobs that are not matched by the query
+func Not(query Query) Query {
+	return &notQuery{query: query}
+}
+
+func (this *notQuery) Validate() error {
+	if this.query == nil {
+		return errors.New("not query with nil subquery")
+	}
+	return this.query.Validate()
+}
+
+func (this *notQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]Query{"not": this.query})
+}
+
+// encodeQuery serializes either Query or the raw map form to the json expected by the /query endpoint
+// any map with string keys is accepted (e.g. named map[string]interface{} types), other json.Marshaler values are sent as they are
+func encodeQuery(query interface{}) ([]byte, error) {
+	switch q := query.(type) {
+	case Query:
+		if q == nil {
+			return nil, ErrEmptyQuery
+		}
+		if err := q.Validate(); err != nil {
+			return nil, err
+		}
+		return json.Marshal(q)
+	case map[string]interface{}:
+		if len(q) == 0 {
+			return nil, ErrEmptyQuery
+		}
+		return json.Marshal(q)
+	case nil:
+		return nil, ErrEmptyQuery
+	case json.Marshaler:
+		return json.Marshal(q)
+	}
+
+	v := reflect.ValueOf(query)
+	if v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String {
+		if v.Len() == 0 {
+			return nil, ErrEmptyQuery
+		}
+		return json.Marshal(query)
+	}
+	return nil, fmt.Errorf("unsupported query type %T, expected Query or map[string]interface{}", query)
+}
+
+// ParseQuery parses the query DSL, tags combined with and, or, not and parentheses, e.g.
+//
+//	a and (b or not c)
+//
+// and binds tighter than or, the keywords are case insensitive, tags that contain spaces, parentheses or are keywords must be double quoted
+func ParseQuery(input string) (Query, error) {
+	tokens, err := tokenizeQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	q, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].value, p.tokens[p.pos].at)
+	}
+	return q, nil
+}
+
+type queryToken struct {
+	value  string
+	quoted bool
+	at     int
+}
+
+func tokenizeQuery(input string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	for i := 0; i < len(input); {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{value: string(c), at: i})
+			i++
+		case c == '"':
+			end := i + 1
+			for end < len(input) && input[end] != '"' {
+				if input[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(input) {
+				return nil, fmt.Errorf("unterminated quote at position %d", i)
+			}
+			value, err := strconv.Unquote(input[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted tag at position %d: %s", i, err.Error())
+			}
+			tokens = append(tokens, queryToken{value: value, quoted: true, at: i})
+			i = end + 1
+		default:
+			end := i
+			for end < len(input) && !strings.ContainsRune(" \t\n\r()\"", rune(input[end])) {
+				end++
+			}
+			tokens = append(tokens, queryToken{value: input[i:end], at: i})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// keyword consumes the next token if it is the unquoted keyword
+func (this *queryParser) keyword(k string) bool {
+	if this.pos < len(this.tokens) && !this.tokens[this.pos].quoted && strings.EqualFold(this.tokens[this.pos].value, k) {
+		this.pos++
+		return true
+	}
+	return false
+}
+
+func (this *queryParser) or() (Query, error) {
+	queries := []Query{}
+	for {
+		q, err := this.and()
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+		if !this.keyword("or") {
+			break
+		}
+	}
+	if len(queries) == 1 {
+		return queries[0], nil
+	}
+	return Or(queries...), nil
+}
+
+func (this *queryParser) and() (Query, error) {
+	queries := []Query{}
+	for {
+		q, err := this.unary()
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, q)
+		if !this.keyword("and") {
+			break
+		}
+	}
+	if len(queries) == 1 {
+		return queries[0], nil
+	}
+	return And(queries...), nil
+}
+
+func (this *queryParser) unary() (Query, error) {
+	if this.pos >= len(this.tokens) {
+		return nil, errors.New("unexpected end of query")
+	}
+
+	if this.keyword("not") {
+		q, err := this.unary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(q), nil
+	}
+
+	if this.keyword("(") {
+		q, err := this.or()
+		if err != nil {
+			return nil, err
+		}
+		if !this.keyword(")") {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		return q, nil
+	}
+
+	t := this.tokens[this.pos]
+	if !t.quoted && (t.value == ")" || strings.EqualFold(t.value, "and") || strings.EqualFold(t.value, "or")) {
+		return nil, fmt.Errorf("unexpected %q at position %d", t.value, t.at)
+	}
+	this.pos++
+	return Tag(t.value), nil
+}
@@ -0,0 +1,99 @@
+package rochefort
+
+import (
+	"testing"
+)
+
+type namedQuery map[string]interface{}
+
+type rawQuery string
+
+func (this rawQuery) MarshalJSON() ([]byte, error) {
+	return []byte(this), nil
+}
+
+func TestQueryEncode(t *testing.T) {
+	cases := []struct {
+		query    interface{}
+		expected string
+	}{
+		{Tag("a"), `{"tag":"a"}`},
+		{Or(Tag("a"), Tag("b")), `{"or":[{"tag":"a"},{"tag":"b"}]}`},
+		{And(Tag("a"), Not(Tag("b"))), `{"and":[{"tag":"a"},{"not":{"tag":"b"}}]}`},
+		{map[string]interface{}{"tag": "a"}, `{"tag":"a"}`},
+		{namedQuery{"or": []interface{}{namedQuery{"tag": "a"}, map[string]string{"tag": "b"}}}, `{"or":[{"tag":"a"},{"tag":"b"}]}`},
+		{rawQuery(`{"tag":"a"}`), `{"tag":"a"}`},
+	}
+
+	for _, c := range cases {
+		j, err := encodeQuery(c.query)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if string(j) != c.expected {
+			t.Logf("unexpected json: %s, expected: %s", string(j), c.expected)
+			t.FailNow()
+		}
+	}
+}
+
+func TestQueryValidate(t *testing.T) {
+	for _, q := range []interface{}{
+		nil,
+		Tag(""),
+		And(),
+		Or(Tag("a"), nil),
+		Not(nil),
+		Not(Or(Tag("a"), Tag(""))),
+		map[string]interface{}{},
+		namedQuery{},
+		map[int]interface{}{1: "a"},
+		"tag:a",
+	} {
+		_, err := encodeQuery(q)
+		if err == nil {
+			t.Logf("expected error for %#v", q)
+			t.FailNow()
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`a`, `{"tag":"a"}`},
+		{`a or b`, `{"or":[{"tag":"a"},{"tag":"b"}]}`},
+		{`a and b or c`, `{"or":[{"and":[{"tag":"a"},{"tag":"b"}]},{"tag":"c"}]}`},
+		{`a AND (b OR NOT c)`, `{"and":[{"tag":"a"},{"or":[{"tag":"b"},{"not":{"tag":"c"}}]}]}`},
+		{`"and" and "x y"`, `{"and":[{"tag":"and"},{"tag":"x y"}]}`},
+		{`not not a`, `{"not":{"not":{"tag":"a"}}}`},
+	}
+
+	for _, c := range cases {
+		q, err := ParseQuery(c.input)
+		if err != nil {
+			t.Logf("%s: %s", c.input, err)
+			t.FailNow()
+		}
+		j, err := encodeQuery(q)
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if string(j) != c.expected {
+			t.Logf("%s: unexpected json: %s, expected: %s", c.input, string(j), c.expected)
+			t.FailNow()
+		}
+	}
+
+	for _, input := range []string{``, `a and`, `(a`, `a)`, `and`, `a b`, `"a`, `not`, `a or or b`} {
+		_, err := ParseQuery(input)
+		if err == nil {
+			t.Logf("expected error for %q", input)
+			t.FailNow()
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package rochefort
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Operation names the Client operations, used by the retry policy and the other per operation settings
+type Operation string
+
+const (
+	OpSet     Operation = "set"
+	OpGet     Operation = "get"
+	OpScan    Operation = "scan"
+	OpSearch  Operation = "search"
+	OpCompact Operation = "compact"
+	OpDelete  Operation = "delete"
+	OpStats   Operation = "stats"
+)
+
+// operations that are safe to repeat, Set is safe only if it does not append or the caller marked it with Idempotent
+var idempotentOperations = map[Operation]bool{
+	OpGet:     true,
+	OpScan:    true,
+	OpSearch:  true,
+	OpStats:   true,
+	OpCompact: true,
+}
+
+type idempotentKey struct{}
+
+// Idempotent marks the requests made with the returned context as safe to retry, use it with SetContext when repeating the appends is acceptable (e.g. the consumers deduplicate)
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(ctx context.Context, op Operation) bool {
+	if idempotentOperations[op] {
+		return true
+	}
+	marked, _ := ctx.Value(idempotentKey{}).(bool)
+	return marked
+}
+
+// RetryPolicy retries the failed requests of the idempotent operations with exponential backoff and jitter
+// Scan and Search are retried only until the response starts streaming, errors in the middle of the stream are returned as is
+type RetryPolicy struct {
+	// total number of attempts including the first one, 1 or less disables the retries
+	MaxAttempts int
+	// backoff before the first retry, multiplied by Multiplier on every next retry, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// 0 means 2
+	Multiplier float64
+	// fraction of the backoff that is randomized, between 0 and 1, e.g. 0.2 means the backoff is between 80% and 100% of the computed value
+	Jitter float64
+	// decides if the error is worth retrying, nil means DefaultRetryable
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns policy with 3 attempts, starting with 50ms backoff up to 1s, and 20% jitter
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 50 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// DefaultRetryable retries status codes 408, 429 and 5xx, and network errors like connection reset, connection refused, timeouts or unexpected EOF; context errors, other status codes and request errors like unsupported scheme or bad tls certificate are not retried
+func DefaultRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code == 408 || statusErr.Code == 429 || statusErr.Code >= 500
+	}
+
+	var frameErr *FrameError
+	if errors.As(err, &frameErr) {
+		return false
+	}
+
+	// http.Client wraps every transport failure in *url.Error, including bad schemes and tls errors, so look at what it wraps
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var timeoutErr interface{ Timeout() bool }
+	if errors.As(err, &timeoutErr) && timeoutErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func (this *RetryPolicy) retryable(err error) bool {
+	if this.Retryable != nil {
+		return this.Retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// backoff returns how long to wait after the given failed attempt (starting from 1)
+func (this *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := this.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	d := float64(this.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= multiplier
+		if this.MaxBackoff > 0 && d > float64(this.MaxBackoff) {
+			break
+		}
+	}
+	if this.MaxBackoff > 0 && d > float64(this.MaxBackoff) {
+		d = float64(this.MaxBackoff)
+	}
+
+	if this.Jitter > 0 {
+		jitter := this.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= d * jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// SetRetryPolicy enables retries of the idempotent operations, nil disables them; it must be called before the client is used
+func (this *Client) SetRetryPolicy(policy *RetryPolicy) {
+	this.retry = policy
+}
+
+// retryable classifies the error with the retry policy of the client, or DefaultRetryable if there is none or the client is not *Client
+func retryable(client Interface, err error) bool {
+	if c, ok := client.(*Client); ok && c.retry != nil {
+		return c.retry.retryable(err)
+	}
+	return DefaultRetryable(err)
+}
+
+// withRetry calls fn until it succeeds, the error is not retryable, the attempts are exhausted or ctx is done
+func (this *Client) withRetry(ctx context.Context, op Operation, fn func() error) error {
+	policy := this.retry
+	if policy == nil || policy.MaxAttempts <= 1 || !isIdempotent(ctx, op) {
+		return fn()
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(policy.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package rochefort_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+func TestRetry(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+	r.SetRetryPolicy(&rochefort.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	})
+
+	input := &rochefort.AppendInput{
+		AppendPayload: []*rochefort.Append{{Namespace: "retry", Data: []byte("abc")}},
+	}
+	out, err := r.Set(input)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	server.FailNext("get", 2, 503)
+	_, err = r.Get(&rochefort.GetInput{GetPayload: []*rochefort.Get{{Namespace: "retry", Offset: out.Offset[0]}}})
+	if err != nil || server.Requests("get") != 3 {
+		t.Logf("expected get to succeed on the 3rd attempt, got: %d, %v", server.Requests("get"), err)
+		t.FailNow()
+	}
+
+	server.FailNext("scan", 3, 500)
+	err = r.Scan("retry", func(offset uint64, data []byte) {})
+	if !errors.Is(err, rochefort.ErrServer) || server.Requests("scan") != 3 {
+		t.Logf("expected scan to give up after 3 attempts, got: %d, %v", server.Requests("scan"), err)
+		t.FailNow()
+	}
+
+	server.FailNext("stat", 1, 404)
+	_, err = r.Stats("retry")
+	if !errors.Is(err, rochefort.ErrNotFound) || server.Requests("stat") != 1 {
+		t.Logf("expected 404 not to be retried, got: %d, %v", server.Requests("stat"), err)
+		t.FailNow()
+	}
+
+	server.FailNext("set", 1, 503)
+	_, err = r.Set(input)
+	if err == nil || server.Requests("set") != 2 {
+		t.Logf("expected append not to be retried, got: %d, %v", server.Requests("set"), err)
+		t.FailNow()
+	}
+
+	server.FailNext("set", 1, 503)
+	_, err = r.SetContext(rochefort.Idempotent(context.Background()), input)
+	if err != nil || server.Requests("set") != 4 {
+		t.Logf("expected idempotent append to be retried, got: %d, %v", server.Requests("set"), err)
+		t.FailNow()
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	attempts := 0
+	r := rochefort.NewClient("ftp://localhost:8000", nil)
+	r.SetRetryPolicy(&rochefort.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Retryable: func(err error) bool {
+			attempts++
+			return rochefort.DefaultRetryable(err)
+		},
+	})
+
+	_, err := r.Stats("retry")
+	if err == nil || attempts != 1 {
+		t.Logf("expected unsupported scheme not to be retried, got: %d, %v", attempts, err)
+		t.FailNow()
+	}
+	if rochefort.DefaultRetryable(err) {
+		t.Logf("expected %v not to be retryable", err)
+		t.FailNow()
+	}
+
+	r = rochefort.NewClient("http://127.0.0.1:1", nil)
+	_, err = r.Stats("retry")
+	if !rochefort.DefaultRetryable(err) {
+		t.Logf("expected %v to be retryable", err)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,356 @@
+package rochefortest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+)
+
+// Mock implements rochefort.Interface without a server, it records every call and answers with the handler programmed for the operation
+// without handler Set returns offset 0 for every append, Get returns nil values, Compact and Delete succeed, Stats returns empty output and Scan and Search return no records
+//
+//	mock := rochefortest.NewMock()
+//	mock.On(rochefort.OpScan, rochefortest.Records(rochefortest.Record{Offset: 0, Data: []byte("a")}))
+//	mock.Fail(rochefort.OpSet, rochefort.ErrServer)
+//	...
+//	mock.AssertCalls(t, rochefort.OpSet, 1)
+type Mock struct {
+	lock     sync.Mutex
+	handlers map[rochefort.Operation]rochefort.Handler
+	calls    []rochefort.Call
+}
+
+var _ rochefort.Interface = (*Mock)(nil)
+
+// NewMock creates mock without programmed handlers
+func NewMock() *Mock {
+	return &Mock{handlers: map[rochefort.Operation]rochefort.Handler{}}
+}
+
+// On programs the response of the operation, the handler sets the Output of the call or passes the records to the Callback of Scan and Search, and returns the error of the call
+// like with the Client, a handler that returns nil without setting the Output makes the call fail with rochefort.ErrNoOutput
+func (this *Mock) On(op rochefort.Operation, handler rochefort.Handler) *Mock {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.handlers[op] = handler
+	return this
+}
+
+// Fail makes every call of the operation return err
+func (this *Mock) Fail(op rochefort.Operation, err error) *Mock {
+	return this.On(op, func(ctx context.Context, call rochefort.Call) error {
+		return err
+	})
+}
+
+// Record is record passed to the Scan and Search callbacks by the Records handler
+type Record struct {
+	Offset uint64
+	Data   []byte
+}
+
+// Records returns handler that passes the records to the Callback of Scan or Search
+func Records(records ...Record) rochefort.Handler {
+	return func(ctx context.Context, call rochefort.Call) error {
+		var callback func(uint64, []byte) error
+		switch c := call.(type) {
+		case *rochefort.ScanCall:
+			callback = c.Callback
+		case *rochefort.SearchCall:
+			callback = c.Callback
+		default:
+			return nil
+		}
+		for _, r := range records {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := callback(r.Offset, r.Data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Calls returns the recorded calls in order, the calls have their Output set by the handlers
+func (this *Mock) Calls() []rochefort.Call {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return append([]rochefort.Call{}, this.calls...)
+}
+
+// CallsOf returns the recorded calls of the operation
+func (this *Mock) CallsOf(op rochefort.Operation) []rochefort.Call {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	var calls []rochefort.Call
+	for _, call := range this.calls {
+		if call.Operation() == op {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// AssertCalls fails the test if the operation was not called exactly n times
+func (this *Mock) AssertCalls(t testing.TB, op rochefort.Operation, n int) {
+	t.Helper()
+	if calls := this.CallsOf(op); len(calls) != n {
+		t.Fatalf("expected %d %s calls, got: %d", n, op, len(calls))
+	}
+}
+
+// Reset forgets the recorded calls and the programmed handlers
+func (this *Mock) Reset() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.calls = nil
+	this.handlers = map[rochefort.Operation]rochefort.Handler{}
+}
+
+// handle records the call and runs its handler, ErrStop returned from Scan and Search callbacks is not reported
+func (this *Mock) handle(ctx context.Context, call rochefort.Call) error {
+	this.lock.Lock()
+	this.calls = append(this.calls, call)
+	handler := this.handlers[call.Operation()]
+	this.lock.Unlock()
+
+	if handler == nil {
+		handler = defaultHandler
+	}
+	err := handler(ctx, call)
+	if err == rochefort.ErrStop {
+		return nil
+	}
+	return err
+}
+
+func defaultHandler(ctx context.Context, call rochefort.Call) error {
+	switch c := call.(type) {
+	case *rochefort.SetCall:
+		c.Output = &rochefort.AppendOutput{Offset: make([]uint64, len(c.Input.AppendPayload))}
+	case *rochefort.GetCall:
+		c.Output = make([][]byte, len(c.Input.GetPayload))
+	case *rochefort.CompactCall:
+		c.Output = &rochefort.SuccessOutput{Success: true}
+	case *rochefort.DeleteCall:
+		c.Output = &rochefort.SuccessOutput{Success: true}
+	case *rochefort.StatsCall:
+		c.Output = &rochefort.StatsOutput{Tags: map[string]uint64{}}
+	}
+	return nil
+}
+
+func (this *Mock) Set(input *rochefort.AppendInput) (*rochefort.AppendOutput, error) {
+	return this.SetContext(context.Background(), input)
+}
+
+func (this *Mock) SetContext(ctx context.Context, input *rochefort.AppendInput) (*rochefort.AppendOutput, error) {
+	call := &rochefort.SetCall{Input: input}
+	if err := this.handle(ctx, call); err != nil {
+		return nil, err
+	}
+	if call.Output == nil {
+		return nil, rochefort.ErrNoOutput
+	}
+	return call.Output, nil
+}
+
+func (this *Mock) Append(namespace string, tags []string, allocSize uint32, data []byte) (uint64, error) {
+	return this.AppendContext(context.Background(), namespace, tags, allocSize, data)
+}
+
+// AppendContext is recorded as Set call with single append
+func (this *Mock) AppendContext(ctx context.Context, namespace string, tags []string, allocSize uint32, data []byte) (uint64, error) {
+	out, err := this.SetContext(ctx, &rochefort.AppendInput{
+		AppendPayload: []*rochefort.Append{{
+			Namespace: namespace,
+			Tags:      tags,
+			AllocSize: allocSize,
+			Data:      data,
+		}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(out.Offset) != 1 {
+		return 0, fmt.Errorf("expected 1 offset, but got: %d", len(out.Offset))
+	}
+	return out.Offset[0], nil
+}
+
+func (this *Mock) Modify(namespace string, offset uint64, position uint32, data []byte) (bool, error) {
+	return this.ModifyContext(context.Background(), namespace, offset, position, data)
+}
+
+// ModifyContext is recorded as Set call with single modification, it is modified if the handler sets ModifiedCount to 1
+// like with the Client, a *rochefort.StatusError rejecting the modification is returned as *rochefort.ModifyError
+func (this *Mock) ModifyContext(ctx context.Context, namespace string, offset uint64, position uint32, data []byte) (bool, error) {
+	if position > math.MaxInt32 {
+		return false, fmt.Errorf("position %d is too big", position)
+	}
+
+	out, err := this.SetContext(ctx, &rochefort.AppendInput{
+		ModifyPayload: []*rochefort.Modify{{
+			Namespace: namespace,
+			Offset:    offset,
+			Pos:       int32(position),
+			Data:      data,
+		}},
+	})
+	if err != nil {
+		var statusErr *rochefort.StatusError
+		if errors.As(err, &statusErr) && statusErr.Code >= 400 && statusErr.Code < 500 && statusErr.Code != 404 && statusErr.Code != 408 && statusErr.Code != 429 {
+			return false, &rochefort.ModifyError{Offset: offset, Position: position, Err: statusErr}
+		}
+		return false, err
+	}
+	return out.ModifiedCount == 1, nil
+}
+
+func (this *Mock) Get(input *rochefort.GetInput) ([][]byte, error) {
+	return this.GetContext(context.Background(), input)
+}
+
+func (this *Mock) GetContext(ctx context.Context, input *rochefort.GetInput) ([][]byte, error) {
+	call := &rochefort.GetCall{Input: input}
+	if err := this.handle(ctx, call); err != nil {
+		return nil, err
+	}
+	if call.Output == nil && len(call.Input.GetPayload) > 0 {
+		return nil, rochefort.ErrNoOutput
+	}
+	return call.Output, nil
+}
+
+func (this *Mock) GetOne(namespace string, offset uint64) ([]byte, error) {
+	return this.GetOneContext(context.Background(), namespace, offset)
+}
+
+// GetOneContext is recorded as Get call with single payload
+func (this *Mock) GetOneContext(ctx context.Context, namespace string, offset uint64) ([]byte, error) {
+	data, err := this.GetMultiContext(ctx, namespace, []uint64{offset})
+	if err != nil {
+		return nil, err
+	}
+	return data[0], nil
+}
+
+func (this *Mock) GetMulti(namespace string, offsets []uint64) ([][]byte, error) {
+	return this.GetMultiContext(context.Background(), namespace, offsets)
+}
+
+// GetMultiContext is recorded as Get call with payload per offset
+func (this *Mock) GetMultiContext(ctx context.Context, namespace string, offsets []uint64) ([][]byte, error) {
+	input := &rochefort.GetInput{
+		GetPayload: make([]*rochefort.Get, len(offsets)),
+	}
+	for i, offset := range offsets {
+		input.GetPayload[i] = &rochefort.Get{
+			Namespace: namespace,
+			Offset:    offset,
+		}
+	}
+
+	data, err := this.GetContext(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != len(offsets) {
+		return nil, fmt.Errorf("expected %d records, but got: %d", len(offsets), len(data))
+	}
+	return data, nil
+}
+
+func (this *Mock) Scan(namespace string, callback func(rochefortOffset uint64, value []byte)) error {
+	return this.ScanContext(context.Background(), namespace, callback)
+}
+
+func (this *Mock) ScanContext(ctx context.Context, namespace string, callback func(rochefortOffset uint64, value []byte)) error {
+	return this.ScanFuncContext(ctx, namespace, func(offset uint64, data []byte) error {
+		callback(offset, data)
+		return nil
+	})
+}
+
+func (this *Mock) ScanFunc(namespace string, callback func(rochefortOffset uint64, value []byte) error) error {
+	return this.ScanFuncContext(context.Background(), namespace, callback)
+}
+
+func (this *Mock) ScanFuncContext(ctx context.Context, namespace string, callback func(rochefortOffset uint64, value []byte) error) error {
+	return this.handle(ctx, &rochefort.ScanCall{Namespace: namespace, Callback: callback})
+}
+
+func (this *Mock) Search(namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte)) error {
+	return this.SearchContext(context.Background(), namespace, query, callback)
+}
+
+func (this *Mock) SearchContext(ctx context.Context, namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte)) error {
+	return this.SearchFuncContext(ctx, namespace, query, func(offset uint64, data []byte) error {
+		callback(offset, data)
+		return nil
+	})
+}
+
+func (this *Mock) SearchFunc(namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte) error) error {
+	return this.SearchFuncContext(context.Background(), namespace, query, callback)
+}
+
+func (this *Mock) SearchFuncContext(ctx context.Context, namespace string, query interface{}, callback func(rochefortOffset uint64, value []byte) error) error {
+	return this.handle(ctx, &rochefort.SearchCall{Namespace: namespace, Query: query, Callback: callback})
+}
+
+func (this *Mock) Compact(input *rochefort.NamespaceInput) (*rochefort.SuccessOutput, error) {
+	return this.CompactContext(context.Background(), input)
+}
+
+func (this *Mock) CompactContext(ctx context.Context, input *rochefort.NamespaceInput) (*rochefort.SuccessOutput, error) {
+	call := &rochefort.CompactCall{Input: input}
+	if err := this.handle(ctx, call); err != nil {
+		return nil, err
+	}
+	if call.Output == nil {
+		return nil, rochefort.ErrNoOutput
+	}
+	return call.Output, nil
+}
+
+func (this *Mock) Delete(input *rochefort.NamespaceInput) (*rochefort.SuccessOutput, error) {
+	return this.DeleteContext(context.Background(), input)
+}
+
+func (this *Mock) DeleteContext(ctx context.Context, input *rochefort.NamespaceInput) (*rochefort.SuccessOutput, error) {
+	call := &rochefort.DeleteCall{Input: input}
+	if err := this.handle(ctx, call); err != nil {
+		return nil, err
+	}
+	if call.Output == nil {
+		return nil, rochefort.ErrNoOutput
+	}
+	return call.Output, nil
+}
+
+func (this *Mock) Stats(namespace string) (*rochefort.StatsOutput, error) {
+	return this.StatsContext(context.Background(), namespace)
+}
+
+func (this *Mock) StatsContext(ctx context.Context, namespace string) (*rochefort.StatsOutput, error) {
+	call := &rochefort.StatsCall{Namespace: namespace}
+	if err := this.handle(ctx, call); err != nil {
+		return nil, err
+	}
+	if call.Output == nil {
+		return nil, rochefort.ErrNoOutput
+	}
+	return call.Output, nil
+}
@@ -0,0 +1,174 @@
+package rochefortest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+)
+
+// countTagged uses only the interface, so it works with the real client and the mock
+func countTagged(r rochefort.Interface, namespace string, tag string) (int, error) {
+	n := 0
+	err := r.Search(namespace, rochefort.Tag(tag), func(offset uint64, data []byte) {
+		n++
+	})
+	return n, err
+}
+
+func TestMock(t *testing.T) {
+	mock := NewMock()
+
+	out, err := mock.Set(&rochefort.AppendInput{AppendPayload: []*rochefort.Append{{Namespace: "a", Data: []byte("x")}}})
+	if err != nil || len(out.Offset) != 1 {
+		t.Logf("unexpected default set: %v, %v", out, err)
+		t.FailNow()
+	}
+
+	mock.On(rochefort.OpSearch, Records(Record{Offset: 0, Data: []byte("a")}, Record{Offset: 17, Data: []byte("b")}))
+	n, err := countTagged(mock, "events", "a")
+	if err != nil || n != 2 {
+		t.Logf("expected 2 records, got: %d, %v", n, err)
+		t.FailNow()
+	}
+
+	scanned := 0
+	mock.On(rochefort.OpScan, Records(Record{Offset: 0}, Record{Offset: 17}, Record{Offset: 34}))
+	err = mock.ScanFuncContext(context.Background(), "events", func(offset uint64, data []byte) error {
+		scanned++
+		return rochefort.ErrStop
+	})
+	if err != nil || scanned != 1 {
+		t.Logf("expected stop after the first record, got: %d, %v", scanned, err)
+		t.FailNow()
+	}
+
+	mock.On(rochefort.OpGet, func(ctx context.Context, call rochefort.Call) error {
+		c := call.(*rochefort.GetCall)
+		for _, g := range c.Input.GetPayload {
+			c.Output = append(c.Output, []byte(g.Namespace))
+		}
+		return nil
+	})
+	data, err := mock.Get(&rochefort.GetInput{GetPayload: []*rochefort.Get{{Namespace: "a"}, {Namespace: "b"}}})
+	if err != nil || len(data) != 2 || string(data[1]) != "b" {
+		t.Logf("unexpected get: %v, %v", data, err)
+		t.FailNow()
+	}
+
+	mock.Fail(rochefort.OpStats, rochefort.ErrServer)
+	_, err = mock.Stats("events")
+	if !errors.Is(err, rochefort.ErrServer) {
+		t.Logf("expected ErrServer, got: %v", err)
+		t.FailNow()
+	}
+
+	mock.On(rochefort.OpCompact, func(ctx context.Context, call rochefort.Call) error {
+		return nil
+	})
+	_, err = mock.Compact(&rochefort.NamespaceInput{Namespace: "events"})
+	if !errors.Is(err, rochefort.ErrNoOutput) {
+		t.Logf("expected ErrNoOutput, got: %v", err)
+		t.FailNow()
+	}
+
+	mock.AssertCalls(t, rochefort.OpSet, 1)
+	mock.AssertCalls(t, rochefort.OpStats, 1)
+	mock.AssertCalls(t, rochefort.OpDelete, 0)
+	calls := mock.Calls()
+	if len(calls) != 6 {
+		t.Logf("expected 6 calls, got: %d", len(calls))
+		t.FailNow()
+	}
+	search := calls[1].(*rochefort.SearchCall)
+	if search.Namespace != "events" {
+		t.Logf("unexpected search call: %+v", search)
+		t.FailNow()
+	}
+
+	mock.Reset()
+	if len(mock.Calls()) != 0 {
+		t.FailNow()
+	}
+
+	// a handler that drops every offset of the input has nothing to return
+	mock.On(rochefort.OpGet, func(ctx context.Context, call rochefort.Call) error {
+		call.(*rochefort.GetCall).Input = &rochefort.GetInput{}
+		return nil
+	})
+	_, err = mock.Get(&rochefort.GetInput{GetPayload: []*rochefort.Get{{Namespace: "a"}}})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	// the rejected modification is reported like by the client
+	mock.Fail(rochefort.OpSet, &rochefort.StatusError{Code: 400, Endpoint: "set"})
+	_, err = mock.Modify("a", 7, 3, []byte("xyz"))
+	var modifyErr *rochefort.ModifyError
+	if !errors.Is(err, rochefort.ErrOutOfBounds) || !errors.As(err, &modifyErr) || modifyErr.Offset != 7 {
+		t.Logf("expected ModifyError, got: %v", err)
+		t.FailNow()
+	}
+}
+
+func TestMockInterface(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+
+	var r rochefort.Interface = rochefort.NewClient(server.URL, nil)
+	_, err := r.Set(&rochefort.AppendInput{AppendPayload: []*rochefort.Append{{Namespace: "events", Tags: []string{"a"}, Data: []byte("x")}}})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	n, err := countTagged(r, "events", "a")
+	if err != nil || n != 1 {
+		t.Logf("expected 1 record, got: %d, %v", n, err)
+		t.FailNow()
+	}
+}
+
+func TestMockHelpers(t *testing.T) {
+	ctx := context.Background()
+	mock := NewMock()
+	mock.On(rochefort.OpGet, func(ctx context.Context, call rochefort.Call) error {
+		c := call.(*rochefort.GetCall)
+		for range c.Input.GetPayload {
+			c.Output = append(c.Output, []byte(`"stored"`))
+		}
+		return nil
+	})
+	mock.On(rochefort.OpScan, Records(Record{Offset: 0, Data: []byte("a")}, Record{Offset: 17, Data: []byte("b")}))
+
+	events := rochefort.NewNamespace[string](mock, "events", rochefort.JSONCodec[string]{}, nil)
+	v, err := events.Get(ctx, 0)
+	if err != nil || v != "stored" {
+		t.Logf("unexpected get: %q, %v", v, err)
+		t.FailNow()
+	}
+
+	b := rochefort.NewBatchAppender(mock, rochefort.BatchConfig{})
+	f := b.Append(&rochefort.Append{Namespace: "events", Data: []byte("c")})
+	b.Close()
+	if _, err := f.Wait(); err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	dst := NewMock()
+	result, err := rochefort.Migrate(ctx, mock, dst, rochefort.MigrateOptions{Namespace: "events"})
+	if err != nil || result.Copied != 2 {
+		t.Logf("unexpected migration: %+v, %v", result, err)
+		t.FailNow()
+	}
+
+	mock.AssertCalls(t, rochefort.OpGet, 1)
+	mock.AssertCalls(t, rochefort.OpSet, 1)
+	dst.AssertCalls(t, rochefort.OpSet, 1)
+	set := dst.CallsOf(rochefort.OpSet)[0].(*rochefort.SetCall)
+	if len(set.Input.AppendPayload) != 2 || string(set.Input.AppendPayload[1].Data) != "b" {
+		t.Logf("unexpected migrated records: %v", set.Input.AppendPayload)
+		t.FailNow()
+	}
+}
@@ -0,0 +1,425 @@
+/*
+this package provides an in-memory fake rochefort server for hermetic tests, it speaks the same wire protocol as https://github.com/jackdoe/rochefort
+
+	server := rochefortest.NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+code that depends on rochefort.Interface can use Mock instead, which needs no server and records the calls
+*/
+package rochefortest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+)
+
+// every stored record takes recordHeaderSize bytes plus its allocated size, so the offsets advance like the real on-disk offsets
+const recordHeaderSize = 16
+
+type record struct {
+	offset uint64
+	alloc  uint32
+	data   []byte
+	tags   []string
+}
+
+type namespace struct {
+	records  []*record
+	byOffset map[uint64]*record
+	tags     map[string][]*record
+	next     uint64
+}
+
+func newNamespace() *namespace {
+	return &namespace{
+		byOffset: map[uint64]*record{},
+		tags:     map[string][]*record{},
+	}
+}
+
+// Server is a fake rochefort server backed by memory, use its URL with rochefort.NewClient
+type Server struct {
+	*httptest.Server
+	lock       sync.Mutex
+	namespaces map[string]*namespace
+	requests   map[string]int
+	failures   map[string][]int
+}
+
+// NewServer starts the fake server, the caller must Close it
+func NewServer() *Server {
+	s := &Server{
+		namespaces: map[string]*namespace{},
+		requests:   map[string]int{},
+		failures:   map[string][]int{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/set", s.set)
+	mux.HandleFunc("/get", s.get)
+	mux.HandleFunc("/scan", s.scan)
+	mux.HandleFunc("/query", s.query)
+	mux.HandleFunc("/compact", s.compact)
+	mux.HandleFunc("/delete", s.delete)
+	mux.HandleFunc("/stat", s.stat)
+	s.Server = httptest.NewServer(s.intercept(mux))
+	return s
+}
+
+// intercept counts the requests and serves the injected failures
+func (this *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.TrimPrefix(r.URL.Path, "/")
+
+		this.lock.Lock()
+		this.requests[endpoint]++
+		code := 0
+		if failures := this.failures[endpoint]; len(failures) > 0 {
+			code = failures[0]
+			this.failures[endpoint] = failures[1:]
+		}
+		this.lock.Unlock()
+
+		if code != 0 {
+			r.Body.Close()
+			http.Error(w, fmt.Sprintf("injected failure %d", code), code)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// FailNext makes the next n requests to the endpoint (e.g. "set", "get", "scan", "query", "stat") fail with the status code, before they reach the fake storage
+func (this *Server) FailNext(endpoint string, n int, code int) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for i := 0; i < n; i++ {
+		this.failures[endpoint] = append(this.failures[endpoint], code)
+	}
+}
+
+// Requests returns how many requests the endpoint received, including the failed ones
+func (this *Server) Requests(endpoint string) int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.requests[endpoint]
+}
+
+// like the real server, the empty namespace is the default one
+func namespaceName(name string) string {
+	if name == "" {
+		return "default"
+	}
+	return name
+}
+
+// namespace returns the namespace, creating it if needed, must be called with the lock held
+func (this *Server) namespace(name string) *namespace {
+	name = namespaceName(name)
+	ns, ok := this.namespaces[name]
+	if !ok {
+		ns = newNamespace()
+		this.namespaces[name] = ns
+	}
+	return ns
+}
+
+type unmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+type marshaler interface {
+	Marshal() ([]byte, error)
+}
+
+func readInput(r *http.Request, input unmarshaler) error {
+	defer r.Body.Close()
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return input.Unmarshal(body)
+}
+
+func writeOutput(w http.ResponseWriter, output marshaler) {
+	data, err := output.Marshal()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(data)
+}
+
+func (this *Server) set(w http.ResponseWriter, r *http.Request) {
+	input := &rochefort.AppendInput{}
+	if err := readInput(r, input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	output := &rochefort.AppendOutput{}
+	for _, m := range input.ModifyPayload {
+		ns := this.namespace(m.Namespace)
+		rec, ok := ns.byOffset[m.Offset]
+		if !ok {
+			http.Error(w, fmt.Sprintf("offset %d not found", m.Offset), http.StatusNotFound)
+			return
+		}
+		capacity := uint32(len(rec.data))
+		if rec.alloc > capacity {
+			capacity = rec.alloc
+		}
+		end := int64(m.Pos) + int64(len(m.Data))
+		if m.Pos < 0 || end > int64(capacity) {
+			http.Error(w, fmt.Sprintf("modify out of bounds, pos: %d, len: %d, allocSize: %d", m.Pos, len(m.Data), capacity), http.StatusBadRequest)
+			return
+		}
+		if end > int64(len(rec.data)) {
+			data := make([]byte, end)
+			copy(data, rec.data)
+			rec.data = data
+		}
+		copy(rec.data[m.Pos:], m.Data)
+		output.ModifiedCount++
+	}
+
+	for _, a := range input.AppendPayload {
+		ns := this.namespace(a.Namespace)
+		alloc := a.AllocSize
+		if uint32(len(a.Data)) > alloc {
+			alloc = uint32(len(a.Data))
+		}
+		rec := &record{
+			offset: ns.next,
+			alloc:  alloc,
+			data:   append([]byte{}, a.Data...),
+			tags:   append([]string{}, a.Tags...),
+		}
+		ns.records = append(ns.records, rec)
+		ns.byOffset[rec.offset] = rec
+		for _, tag := range rec.tags {
+			ns.tags[tag] = append(ns.tags[tag], rec)
+		}
+		ns.next += recordHeaderSize + uint64(alloc)
+		output.Offset = append(output.Offset, rec.offset)
+	}
+
+	writeOutput(w, output)
+}
+
+func (this *Server) get(w http.ResponseWriter, r *http.Request) {
+	input := &rochefort.GetInput{}
+	if err := readInput(r, input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	output := &rochefort.GetOutput{}
+	for _, g := range input.GetPayload {
+		rec, ok := this.namespace(g.Namespace).byOffset[g.Offset]
+		if !ok {
+			http.Error(w, fmt.Sprintf("offset %d not found", g.Offset), http.StatusNotFound)
+			return
+		}
+		output.Data = append(output.Data, append([]byte{}, rec.data...))
+	}
+	writeOutput(w, output)
+}
+
+func writeFrames(w http.ResponseWriter, records []*record) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	header := make([]byte, 12)
+	for _, rec := range records {
+		binary.LittleEndian.PutUint32(header, uint32(len(rec.data)))
+		binary.LittleEndian.PutUint64(header[4:], rec.offset)
+		w.Write(header)
+		w.Write(rec.data)
+	}
+}
+
+// snapshot copies the records so they can be written without holding the lock
+func snapshot(records []*record) []*record {
+	out := make([]*record, len(records))
+	for i, rec := range records {
+		out[i] = &record{offset: rec.offset, data: append([]byte{}, rec.data...)}
+	}
+	return out
+}
+
+func (this *Server) scan(w http.ResponseWriter, r *http.Request) {
+	this.lock.Lock()
+	records := snapshot(this.namespace(r.URL.Query().Get("namespace")).records)
+	this.lock.Unlock()
+
+	writeFrames(w, records)
+}
+
+func (this *Server) query(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var q interface{}
+	if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	this.lock.Lock()
+	ns := this.namespace(r.URL.Query().Get("namespace"))
+	matched, err := ns.eval(q)
+	var records []*record
+	if err == nil {
+		for _, rec := range ns.records {
+			if matched[rec] {
+				records = append(records, rec)
+			}
+		}
+		records = snapshot(records)
+	}
+	this.lock.Unlock()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeFrames(w, records)
+}
+
+// eval returns the set of records matching the json query, supports tag, and, or and not
+func (this *namespace) eval(q interface{}) (map[*record]bool, error) {
+	mapped, ok := q.(map[string]interface{})
+	if !ok || len(mapped) != 1 {
+		return nil, errors.New("query must be an object with exactly one key")
+	}
+
+	for k, v := range mapped {
+		switch k {
+		case "tag":
+			tag, ok := v.(string)
+			if !ok {
+				return nil, errors.New("tag must be a string")
+			}
+			out := map[*record]bool{}
+			for _, rec := range this.tags[tag] {
+				out[rec] = true
+			}
+			return out, nil
+		case "and", "or":
+			list, ok := v.([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("%s must be a non empty list", k)
+			}
+			var out map[*record]bool
+			for _, sub := range list {
+				matched, err := this.eval(sub)
+				if err != nil {
+					return nil, err
+				}
+				if out == nil {
+					out = matched
+					continue
+				}
+				if k == "or" {
+					for rec := range matched {
+						out[rec] = true
+					}
+				} else {
+					for rec := range out {
+						if !matched[rec] {
+							delete(out, rec)
+						}
+					}
+				}
+			}
+			return out, nil
+		case "not":
+			matched, err := this.eval(v)
+			if err != nil {
+				return nil, err
+			}
+			out := map[*record]bool{}
+			for _, rec := range this.records {
+				if !matched[rec] {
+					out[rec] = true
+				}
+			}
+			return out, nil
+		default:
+			return nil, fmt.Errorf("unknown query type %s", k)
+		}
+	}
+	return nil, nil
+}
+
+func (this *Server) compact(w http.ResponseWriter, r *http.Request) {
+	input := &rochefort.NamespaceInput{}
+	if err := readInput(r, input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// records are never moved in memory, so there is nothing to compact
+	writeOutput(w, &rochefort.SuccessOutput{Success: true})
+}
+
+func (this *Server) delete(w http.ResponseWriter, r *http.Request) {
+	input := &rochefort.NamespaceInput{}
+	if err := readInput(r, input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	this.lock.Lock()
+	delete(this.namespaces, namespaceName(input.Namespace))
+	this.lock.Unlock()
+
+	writeOutput(w, &rochefort.SuccessOutput{Success: true})
+}
+
+func (this *Server) stat(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("namespace")
+
+	this.lock.Lock()
+	ns := this.namespace(name)
+	output := &rochefort.StatsOutput{
+		Tags:   map[string]uint64{},
+		Offset: ns.next,
+		File:   fmt.Sprintf("/fake/%s/append.raw", namespaceName(name)),
+	}
+	for tag, records := range ns.tags {
+		output.Tags[tag] = uint64(len(records))
+	}
+	this.lock.Unlock()
+
+	writeOutput(w, output)
+}
+
+// Namespaces returns the sorted names of the namespaces that exist in the server
+func (this *Server) Namespaces() []string {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	out := []string{}
+	for name := range this.namespaces {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,158 @@
+package rochefortest
+
+import (
+	"bytes"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+)
+
+func TestModify(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	out, err := r.Set(&rochefort.AppendInput{
+		AppendPayload: []*rochefort.Append{{
+			Namespace: "modify",
+			AllocSize: 5,
+			Data:      []byte("abc"),
+		}},
+	})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	off := out.Offset[0]
+
+	out, err = r.Set(&rochefort.AppendInput{
+		ModifyPayload: []*rochefort.Modify{{
+			Namespace: "modify",
+			Offset:    off,
+			Pos:       1,
+			Data:      []byte("zxcv"),
+		}},
+	})
+	if err != nil || out.ModifiedCount != 1 {
+		t.Logf("unexpected modify result: %v, %v", out, err)
+		t.FailNow()
+	}
+
+	data, err := r.Get(&rochefort.GetInput{
+		GetPayload: []*rochefort.Get{{Namespace: "modify", Offset: off}},
+	})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if string(data[0]) != "azxcv" {
+		t.Logf("unexpected read: %s", string(data[0]))
+		t.FailNow()
+	}
+
+	_, err = r.Set(&rochefort.AppendInput{
+		ModifyPayload: []*rochefort.Modify{{
+			Namespace: "modify",
+			Offset:    off,
+			Pos:       3,
+			Data:      []byte("zxcv"),
+		}},
+	})
+	if err == nil {
+		t.Log("expected out of bounds error")
+		t.FailNow()
+	}
+}
+
+func TestScanSearchStats(t *testing.T) {
+	server := NewServer()
+	defer server.Close()
+	r := rochefort.NewClient(server.URL, nil)
+
+	values := []string{"aaa", "bbb", "ab"}
+	tags := [][]string{{"a"}, {"b"}, {"a", "b"}}
+	offsets := []uint64{}
+	for i := range values {
+		out, err := r.Set(&rochefort.AppendInput{
+			AppendPayload: []*rochefort.Append{{
+				Namespace: "ns",
+				Tags:      tags[i],
+				Data:      []byte(values[i]),
+			}},
+		})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		offsets = append(offsets, out.Offset[0])
+	}
+
+	scanned := [][]byte{}
+	err := r.Scan("ns", func(offset uint64, data []byte) {
+		if offset != offsets[len(scanned)] {
+			t.Logf("unexpected offset: %d", offset)
+			t.FailNow()
+		}
+		scanned = append(scanned, data)
+	})
+	if err != nil || len(scanned) != 3 || !bytes.Equal(scanned[2], []byte("ab")) {
+		t.Logf("unexpected scan: %v, %v", scanned, err)
+		t.FailNow()
+	}
+
+	cases := []struct {
+		query    rochefort.Query
+		expected []string
+	}{
+		{rochefort.Tag("a"), []string{"aaa", "ab"}},
+		{rochefort.Or(rochefort.Tag("a"), rochefort.Tag("b")), []string{"aaa", "bbb", "ab"}},
+		{rochefort.And(rochefort.Tag("a"), rochefort.Tag("b")), []string{"ab"}},
+		{rochefort.And(rochefort.Tag("a"), rochefort.Not(rochefort.Tag("b"))), []string{"aaa"}},
+	}
+	for _, c := range cases {
+		found := []string{}
+		err := r.Search("ns", c.query, func(offset uint64, data []byte) {
+			found = append(found, string(data))
+		})
+		if err != nil {
+			t.Log(err)
+			t.FailNow()
+		}
+		if len(found) != len(c.expected) {
+			t.Logf("unexpected search result: %v, expected: %v", found, c.expected)
+			t.FailNow()
+		}
+		for i := range found {
+			if found[i] != c.expected[i] {
+				t.Logf("unexpected search result: %v, expected: %v", found, c.expected)
+				t.FailNow()
+			}
+		}
+	}
+
+	stats, err := r.Stats("ns")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if stats.Tags["a"] != 2 || stats.Tags["b"] != 2 || stats.Offset <= offsets[2] {
+		t.Logf("unexpected stats: %v", stats)
+		t.FailNow()
+	}
+
+	_, err = r.Compact(&rochefort.NamespaceInput{Namespace: "ns"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	_, err = r.Delete(&rochefort.NamespaceInput{Namespace: "ns"})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	if len(server.Namespaces()) != 0 {
+		t.Logf("expected no namespaces after delete, got: %v", server.Namespaces())
+		t.FailNow()
+	}
+}
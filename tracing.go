@@ -0,0 +1,116 @@
+package rochefort
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+)
+
+// Attribute is key value pair attached to span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Tracer starts span around every Client operation, the span name is "rochefort." followed by the operation (e.g. "rochefort.set")
+// the attributes are rochefort.operation, rochefort.namespace and rochefort.payloads at the start,
+// http.response.status_code, rochefort.bytes_sent and rochefort.bytes_received at the finish
+type Tracer interface {
+	StartSpan(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span is single traced operation
+type Span interface {
+	SetAttributes(attributes ...Attribute)
+	// TraceParent returns the W3C traceparent of the span, it is sent as traceparent header with every request of the operation, empty string means no header
+	TraceParent() string
+	// Finish ends the span, err is nil if the operation succeeded
+	Finish(err error)
+}
+
+// NoopTracer does nothing, it is the default
+type NoopTracer struct{}
+
+func (NoopTracer) StartSpan(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(attributes ...Attribute) {}
+func (noopSpan) TraceParent() string                   { return "" }
+func (noopSpan) Finish(err error)                      {}
+
+// WithTracer traces the client operations with the tracer, see OTelTracer for opentelemetry
+func WithTracer(tracer Tracer) Option {
+	return func(o *options) {
+		o.tracer = tracer
+	}
+}
+
+// TraceParent formats the W3C traceparent header value, version 00
+func TraceParent(traceID [16]byte, spanID [8]byte, sampled bool) string {
+	flags := "00"
+	if sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(traceID[:]), hex.EncodeToString(spanID[:]), flags)
+}
+
+// OTelSpan is the part of the opentelemetry span used by OTelTracer, with plain types so this package does not depend on opentelemetry
+type OTelSpan interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+	// SpanContext returns the ids of the span, zero trace id means the span is not valid and no traceparent is sent
+	SpanContext() (traceID [16]byte, spanID [8]byte, sampled bool)
+}
+
+// OTelTracer adapts opentelemetry to Tracer, Start wraps the opentelemetry tracer in few lines:
+//
+//	tracer := otel.Tracer("rochefort")
+//	client := rochefort.New(url, rochefort.WithTracer(rochefort.OTelTracer{
+//		Start: func(ctx context.Context, name string) (context.Context, rochefort.OTelSpan) {
+//			ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//			return ctx, otelSpan{span}
+//		},
+//	}))
+//
+// where otelSpan implements OTelSpan by converting the attribute values with attribute.String, attribute.Int64 etc,
+// calling span.RecordError and span.SetStatus(codes.Error, err.Error()) in RecordError,
+// and returning span.SpanContext().TraceID(), SpanID() and IsSampled() from SpanContext
+type OTelTracer struct {
+	Start func(ctx context.Context, name string) (context.Context, OTelSpan)
+}
+
+func (this OTelTracer) StartSpan(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	ctx, span := this.Start(ctx, name)
+	s := &otelSpan{span: span}
+	s.SetAttributes(attributes...)
+	return ctx, s
+}
+
+type otelSpan struct {
+	span OTelSpan
+}
+
+func (this *otelSpan) SetAttributes(attributes ...Attribute) {
+	for _, a := range attributes {
+		this.span.SetAttribute(a.Key, a.Value)
+	}
+}
+
+func (this *otelSpan) TraceParent() string {
+	traceID, spanID, sampled := this.span.SpanContext()
+	if traceID == [16]byte{} {
+		return ""
+	}
+	return TraceParent(traceID, spanID, sampled)
+}
+
+func (this *otelSpan) Finish(err error) {
+	if err != nil {
+		this.span.RecordError(err)
+	}
+	this.span.End()
+}
@@ -0,0 +1,121 @@
+package rochefort_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	rochefort "github.com/jackdoe/go-rochefort-client"
+	"github.com/jackdoe/go-rochefort-client/rochefortest"
+)
+
+type testSpan struct {
+	name       string
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+func (this *testSpan) SetAttribute(key string, value interface{}) {
+	this.attributes[key] = value
+}
+
+func (this *testSpan) RecordError(err error) {
+	this.err = err
+}
+
+func (this *testSpan) End() {
+	this.ended = true
+}
+
+func (this *testSpan) SpanContext() ([16]byte, [8]byte, bool) {
+	return [16]byte{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}, [8]byte{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7}, true
+}
+
+// headerTransport records the traceparent header of every request
+type headerTransport struct {
+	lock         sync.Mutex
+	traceParents []string
+}
+
+func (this *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	this.lock.Lock()
+	this.traceParents = append(this.traceParents, req.Header.Get("traceparent"))
+	this.lock.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTracer(t *testing.T) {
+	server := rochefortest.NewServer()
+	defer server.Close()
+
+	var spans []*testSpan
+	tracer := rochefort.OTelTracer{
+		Start: func(ctx context.Context, name string) (context.Context, rochefort.OTelSpan) {
+			span := &testSpan{name: name, attributes: map[string]interface{}{}}
+			spans = append(spans, span)
+			return ctx, span
+		},
+	}
+	transport := &headerTransport{}
+	r := rochefort.New(server.URL, rochefort.WithTracer(tracer), rochefort.WithHTTPClient(&http.Client{Transport: transport}))
+	ctx := context.Background()
+
+	offset, err := r.AppendContext(ctx, "events", []string{"a"}, 0, []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+	_, err = r.GetOneContext(ctx, "events", offset+1)
+	if !errors.Is(err, rochefort.ErrNotFound) {
+		t.Logf("expected not found, got: %v", err)
+		t.FailNow()
+	}
+	err = r.SearchContext(ctx, "events", rochefort.Tag("a"), func(offset uint64, data []byte) {})
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	if len(spans) != 3 || spans[0].name != "rochefort.set" || spans[1].name != "rochefort.get" || spans[2].name != "rochefort.search" {
+		t.Logf("unexpected spans: %v", spans)
+		t.FailNow()
+	}
+	for _, span := range spans {
+		if !span.ended || span.attributes["rochefort.namespace"] != "events" || span.attributes["rochefort.payloads"] != 1 {
+			t.Logf("unexpected span: %+v", span)
+			t.FailNow()
+		}
+	}
+	if spans[0].err != nil || spans[0].attributes["http.response.status_code"] != 200 || spans[0].attributes["rochefort.bytes_sent"].(int64) == 0 {
+		t.Logf("unexpected set span: %+v", spans[0])
+		t.FailNow()
+	}
+	if !errors.Is(spans[1].err, rochefort.ErrNotFound) || spans[1].attributes["http.response.status_code"] != 404 {
+		t.Logf("unexpected get span: %+v", spans[1])
+		t.FailNow()
+	}
+
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if len(transport.traceParents) != 3 {
+		t.Logf("expected 3 requests, got: %v", transport.traceParents)
+		t.FailNow()
+	}
+	for _, traceParent := range transport.traceParents {
+		if traceParent != expected {
+			t.Logf("unexpected traceparent: %s", traceParent)
+			t.FailNow()
+		}
+	}
+
+	// the noop tracer does not send the header
+	transport.traceParents = nil
+	r = rochefort.New(server.URL, rochefort.WithTracer(rochefort.NoopTracer{}), rochefort.WithHTTPClient(&http.Client{Transport: transport}))
+	_, err = r.Stats("events")
+	if err != nil || len(transport.traceParents) != 1 || transport.traceParents[0] != "" {
+		t.Logf("unexpected traceparent: %v, %v", transport.traceParents, err)
+		t.FailNow()
+	}
+}